@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"tlgread/pkg/tlgcore"
+)
+
+// eachToken runs fn over the tokens of one work, of one author, or of the
+// whole corpus, in text order.
+func eachToken(corpus *tlgcore.Corpus, author, workID string, kinds []string, fn func(tlgcore.Token)) error {
+	texts := []string{author}
+	if author == "" {
+		var err error
+		texts, err = corpus.Texts(kinds...)
+		if err != nil {
+			return err
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("no text files found")
+		}
+	}
+
+	for _, textID := range texts {
+		p, err := corpus.Open(textID)
+		if err != nil {
+			return err
+		}
+		for tok, err := range p.Tokens(workID) {
+			if err != nil {
+				p.Close()
+				return fmt.Errorf("%s: %v", textID, err)
+			}
+			fn(tok)
+		}
+		p.Close()
+	}
+	return nil
+}
+
+// loadStopwords reads one word or lemma per line, in Greek or Beta Code.
+// Blank lines and lines starting with # are skipped.
+func loadStopwords(path string, isLatin bool) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stop := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		w := strings.TrimSpace(scanner.Text())
+		if w == "" || strings.HasPrefix(w, "#") {
+			continue
+		}
+		stop[tlgcore.FormKey(w, isLatin)] = true
+	}
+	return stop, scanner.Err()
+}
+
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
+
+func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	dirPath := flag.String("d", ".", "corpus root containing tlg*.txt / lat*.txt")
+	author := flag.String("author", "", "author file, e.g. tlg0012 (default: whole corpus)")
+	workID := flag.String("w", "", "work ID within -author (default: all works)")
+	isLatin := flag.Bool("lat", false, "use Latin (PHI) texts")
+	kind := flag.String("kind", "", tlgcore.TextKindUsage)
+	word := flag.String("word", "", "node word in Greek / Beta Code (accents ignored)")
+	lemma := flag.String("lemma", "", "node lemma (needs -a)")
+	by := flag.String("by", "form", "count collocates by form or lemma (needs -a)")
+	analysesPath := flag.String("a", "", "greek-analyses.txt (latin-analyses.txt with -lat)")
+	window := flag.Int("window", 5, "words on each side of the node")
+	stopPath := flag.String("stop", "", "stopword list, one form or lemma per line")
+	minCount := flag.Int("min", 3, "least co-occurrences to report")
+	sortBy := flag.String("sort", "ll", "rank by mi, t or ll")
+	top := flag.Int("n", 50, "rows to print (0 for all)")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
+	flag.Parse()
+
+	if (*word == "") == (*lemma == "") || *workID != "" && *author == "" {
+		return errors.New("Usage: colloc -d corpus (-word word | -lemma lemma -a analyses) [-author tlgNNNN [-w work]] [-by form|lemma]")
+	}
+	if *by != "form" && *by != "lemma" {
+		return fmt.Errorf("unknown -by %q (want form or lemma)", *by)
+	}
+	if (*lemma != "" || *by == "lemma") && *analysesPath == "" {
+		return errors.New("-lemma and -by lemma need the analyses file (-a)")
+	}
+	if *window < 1 {
+		return errors.New("-window must be at least 1")
+	}
+	if err := tlgcore.SortCollocates(nil, *sortBy); err != nil {
+		return err
+	}
+	kinds, err := tlgcore.ParseTextKinds(*kind, *isLatin)
+	if err != nil {
+		return err
+	}
+
+	var stop map[string]bool
+	if *stopPath != "" {
+		var err error
+		if stop, err = loadStopwords(*stopPath, *isLatin); err != nil {
+			return err
+		}
+	}
+
+	corpus := tlgcore.OpenCorpus(*dirPath)
+
+	// Lemmata are looked up for every form of the text, which takes a
+	// first pass to collect them.
+	var lemmas map[string][]string
+	if *analysesPath != "" {
+		forms := make(map[string]bool)
+		err := eachToken(corpus, *author, *workID, kinds, func(tok tlgcore.Token) {
+			forms[tlgcore.FormKey(tok.Text, *isLatin)] = true
+		})
+		if err != nil {
+			return err
+		}
+		if lemmas, err = tlgcore.FormLemmas(*analysesPath, forms); err != nil {
+			return err
+		}
+	}
+
+	var isNode func(tlgcore.Token) bool
+	if *lemma != "" {
+		target := tlgcore.MorphKey(tlgcore.LemmaQuery(*lemma))
+		isNode = func(t tlgcore.Token) bool {
+			return slices.ContainsFunc(lemmas[tlgcore.FormKey(t.Text, *isLatin)], func(l string) bool {
+				return tlgcore.MorphKey(l) == target
+			})
+		}
+	} else {
+		key := tlgcore.WordKey(*word)
+		isNode = func(t tlgcore.Token) bool { return t.Key == key }
+	}
+
+	units := func(t tlgcore.Token) []string { return []string{tlgcore.FormKey(t.Text, *isLatin)} }
+	if *by == "lemma" {
+		units = func(t tlgcore.Token) []string { return lemmas[tlgcore.FormKey(t.Text, *isLatin)] }
+	}
+
+	cc := tlgcore.NewCollocationCounter(*window, isNode, units)
+	if err := eachToken(corpus, *author, *workID, kinds, cc.Add); err != nil {
+		return err
+	}
+	if cc.Nodes == 0 {
+		return errors.New("node not found")
+	}
+
+	var cs []tlgcore.Collocate
+	for _, c := range cc.Collocates(*minCount) {
+		if !stop[tlgcore.MorphKey(c.Key)] {
+			cs = append(cs, c)
+		}
+	}
+	tlgcore.SortCollocates(cs, *sortBy)
+
+	fmt.Fprintf(stdout, "node %d  window ±%d  tokens %d  collocates %d\n", cc.Nodes, *window, cc.Freq.Total, len(cs))
+	fmt.Fprintf(stdout, "%6s %8s %8s %9s %8s %8s %10s  %s\n", "rank", "observed", "freq", "expected", "MI", "t", "LL", *by)
+	for i, c := range cs {
+		if *top > 0 && i >= *top {
+			break
+		}
+		key := c.Key
+		if !*isLatin {
+			key = tlgcore.ToGreek(key)
+		}
+		fmt.Fprintf(stdout, "%6d %8d %8d %9.2f %8.2f %8.2f %10.2f  %s\n",
+			i+1, c.Observed, c.Freq, c.Expected, c.MI, c.TScore, c.LogLik, key)
+	}
+	return nil
+}
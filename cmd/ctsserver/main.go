@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"tlgread/pkg/tlgcore"
+)
+
+// CTS error codes.
+const (
+	errInvalidRequest = 1
+	errInvalidParam   = 2
+	errInvalidURN     = 3
+	errInvalidRef     = 4
+	errInvalidLevel   = 5
+	errCommand        = 6
+)
+
+type ctsError struct {
+	XMLName xml.Name `xml:"http://chs.harvard.edu/xmlns/cts CTSError" json:"-"`
+	Message string   `xml:"message" json:"message"`
+	Code    int      `xml:"code" json:"code"`
+}
+
+func (e *ctsError) Error() string { return e.Message }
+
+func newError(code int, format string, args ...any) *ctsError {
+	return &ctsError{Message: fmt.Sprintf(format, args...), Code: code}
+}
+
+type request struct {
+	Name  string `xml:"requestName" json:"requestName"`
+	URN   string `xml:"requestUrn,omitempty" json:"requestUrn,omitempty"`
+	Level string `xml:"requestLevel,omitempty" json:"requestLevel,omitempty"`
+}
+
+type citation struct {
+	Label    string    `xml:"label,attr" json:"label"`
+	Citation *citation `xml:"citation,omitempty" json:"citation,omitempty"`
+}
+
+type work struct {
+	URN      string    `xml:"urn,attr" json:"urn"`
+	Lang     string    `xml:"http://www.w3.org/XML/1998/namespace lang,attr" json:"lang"`
+	Title    string    `xml:"title" json:"title"`
+	Citation *citation `xml:"online>citationMapping>citation,omitempty" json:"citation,omitempty"`
+}
+
+type textGroup struct {
+	URN   string `xml:"urn,attr" json:"urn"`
+	Name  string `xml:"groupname" json:"groupname"`
+	Works []work `xml:"work" json:"works"`
+}
+
+type capabilities struct {
+	XMLName xml.Name `xml:"http://chs.harvard.edu/xmlns/cts GetCapabilities" json:"-"`
+	Request request  `xml:"request" json:"request"`
+	Reply   struct {
+		Inventory struct {
+			Groups []textGroup `xml:"textgroup" json:"textgroups"`
+		} `xml:"TextInventory" json:"inventory"`
+	} `xml:"reply" json:"reply"`
+}
+
+type validReff struct {
+	XMLName xml.Name `xml:"http://chs.harvard.edu/xmlns/cts GetValidReff" json:"-"`
+	Request request  `xml:"request" json:"request"`
+	Reply   struct {
+		URNs []string `xml:"reff>urn" json:"reff"`
+	} `xml:"reply" json:"reply"`
+}
+
+type passageLine struct {
+	URN      string `json:"urn"`
+	Citation string `json:"citation"`
+	Text     string `json:"text"`
+}
+
+// passage carries the TEI of a passage in XML and its lines in JSON.
+type passage struct {
+	TEI   string        `xml:",innerxml" json:"-"`
+	Lines []passageLine `xml:"-" json:"lines"`
+}
+
+type prevNext struct {
+	Prev string `xml:"prev>urn" json:"prev"`
+	Next string `xml:"next>urn" json:"next"`
+}
+
+type label struct {
+	GroupName string `xml:"groupname" json:"groupname"`
+	Title     string `xml:"title" json:"title"`
+	Citation  string `xml:"citation" json:"citation"`
+}
+
+type passageReply struct {
+	URN      string    `xml:"urn" json:"urn"`
+	Label    *label    `xml:"label,omitempty" json:"label,omitempty"`
+	Passage  passage   `xml:"passage" json:"passage"`
+	PrevNext *prevNext `xml:"prevnext,omitempty" json:"prevnext,omitempty"`
+}
+
+type getPassage struct {
+	XMLName xml.Name     `xml:"http://chs.harvard.edu/xmlns/cts GetPassage" json:"-"`
+	Request request      `xml:"request" json:"request"`
+	Reply   passageReply `xml:"reply" json:"reply"`
+}
+
+type getPassagePlus struct {
+	XMLName xml.Name     `xml:"http://chs.harvard.edu/xmlns/cts GetPassagePlus" json:"-"`
+	Request request      `xml:"request" json:"request"`
+	Reply   passageReply `xml:"reply" json:"reply"`
+}
+
+type getPrevNext struct {
+	XMLName xml.Name `xml:"http://chs.harvard.edu/xmlns/cts GetPrevNextUrn" json:"-"`
+	Request request  `xml:"request" json:"request"`
+	Reply   struct {
+		URN      string   `xml:"urn" json:"urn"`
+		PrevNext prevNext `xml:"prevnext" json:"prevnext"`
+	} `xml:"reply" json:"reply"`
+}
+
+type server struct {
+	corpus *tlgcore.Corpus
+	norm   tlgcore.OutputForm
+
+	invOnce sync.Once
+	groups  []textGroup
+	invErr  error
+}
+
+func citationMapping(meta *tlgcore.WorkMetadata) *citation {
+	var root *citation
+	next := &root
+	seen := make(map[string]bool)
+	for _, def := range meta.Citations {
+		if seen[def.LevelChar] {
+			continue
+		}
+		seen[def.LevelChar] = true
+		*next = &citation{Label: def.Label}
+		next = &(*next).Citation
+	}
+	return root
+}
+
+// inventory lists every text file with the works of its IDT. It is built
+// on first use and kept.
+func (s *server) inventory() ([]textGroup, error) {
+	s.invOnce.Do(func() {
+		s.groups, s.invErr = s.readInventory()
+	})
+	return s.groups, s.invErr
+}
+
+func (s *server) readInventory() ([]textGroup, error) {
+	var groups []textGroup
+	texts, err := s.corpus.Texts()
+	if err != nil {
+		return nil, err
+	}
+	for _, textID := range texts {
+		idt, err := s.corpus.IDT(textID)
+		if err != nil {
+			continue
+		}
+		base, name := s.corpus.Author(textID)
+		u := tlgcore.WorkURN(textID, "")
+		g := textGroup{
+			URN:  fmt.Sprintf("urn:cts:%s:%s", u.Namespace, u.TextGroup),
+			Name: name,
+		}
+
+		ids := make([]string, 0, len(idt))
+		for id := range idt {
+			ids = append(ids, id)
+		}
+		slices.SortFunc(ids, func(a, b string) int {
+			na, _ := strconv.Atoi(a)
+			nb, _ := strconv.Atoi(b)
+			return na - nb
+		})
+		lang := "grc"
+		switch {
+		case tlgcore.IsLatinFileName(base):
+			lang = "la"
+		case tlgcore.IsCopticFileName(base):
+			lang = "cop"
+		}
+		for _, id := range ids {
+			meta := idt[id]
+			g.Works = append(g.Works, work{
+				URN:      tlgcore.WorkURN(textID, id).String(),
+				Lang:     lang,
+				Title:    meta.Title,
+				Citation: citationMapping(meta),
+			})
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// text is an opened work named by a URN.
+type text struct {
+	urn    tlgcore.URN
+	textID string
+	parser *tlgcore.Parser
+	meta   *tlgcore.WorkMetadata
+}
+
+func (t *text) Close() { t.parser.Close() }
+
+func (s *server) open(urn string) (*text, error) {
+	u, err := tlgcore.ParseURN(urn)
+	if err != nil {
+		return nil, newError(errInvalidURN, "%v", err)
+	}
+	textID, err := s.corpus.FindText(u.TextID())
+	if err != nil {
+		return nil, newError(errInvalidRef, "%v", err)
+	}
+
+	idt, err := s.corpus.IDT(textID)
+	if err != nil {
+		return nil, newError(errInvalidRef, "%s: %v", u, err)
+	}
+	meta := idt[u.WorkID()]
+	if meta == nil {
+		return nil, newError(errInvalidRef, "%s: no work %s in %s", u, u.WorkID(), textID)
+	}
+
+	p, err := s.corpus.Open(textID)
+	if err != nil {
+		return nil, newError(errInvalidRef, "%v", err)
+	}
+	return &text{urn: u, textID: textID, parser: p, meta: meta}, nil
+}
+
+func (t *text) lines(from, to string) ([]tlgcore.Line, error) {
+	lines, err := t.parser.PassageLines(t.urn.WorkID(), from, to)
+	if err != nil {
+		return nil, newError(errInvalidRef, "%v", err)
+	}
+	return lines, nil
+}
+
+func (t *text) depth() int {
+	seen := make(map[string]bool)
+	for _, def := range t.meta.Citations {
+		seen[def.LevelChar] = true
+	}
+	return len(seen)
+}
+
+// reffs returns the distinct citations at the given depth of the lines
+// between from and to (the whole work if both are empty).
+func (t *text) reffs(from, to string, level int) ([]string, error) {
+	lines, err := t.lines(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	seen := make(map[string]bool)
+	for _, l := range lines {
+		parts := strings.Split(l.FormattedCitation, ".")
+		if len(parts) < level {
+			continue
+		}
+		ref := strings.Join(parts[:level], ".")
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+func (t *text) passageURN(from, to string) string {
+	u := t.urn
+	u.From, u.To = from, to
+	return u.String()
+}
+
+func (t *text) passage(from, to string) (passage, error) {
+	lines, err := t.lines(from, to)
+	if err != nil {
+		return passage{}, err
+	}
+	var buf bytes.Buffer
+	if err := t.parser.WriteTEIPassage(&buf, t.urn.WorkID(), lines); err != nil {
+		return passage{}, newError(errCommand, "%v", err)
+	}
+	ps := passage{TEI: buf.String()}
+	for _, l := range lines {
+		ps.Lines = append(ps.Lines, passageLine{
+			URN:      tlgcore.CitationURN(t.textID, t.urn.WorkID(), l.FormattedCitation),
+			Citation: l.FormattedCitation,
+			Text:     l.Text,
+		})
+	}
+	return ps, nil
+}
+
+// prevNext finds the passages of the same size before and after the one
+// the URN names, at the depth of its citation.
+func (t *text) prevNext() (prevNext, error) {
+	var pn prevNext
+	if t.urn.From == "" {
+		return pn, nil
+	}
+	level := len(strings.Split(t.urn.From, "."))
+	refs, err := t.reffs("", "", level)
+	if err != nil {
+		return pn, err
+	}
+	i := slices.Index(refs, t.urn.From)
+	j := slices.Index(refs, t.urn.To)
+	if i < 0 || j < i {
+		return pn, newError(errInvalidRef, "%s: passage not found at citation depth %d", t.urn, level)
+	}
+
+	span := j - i + 1
+	if i > 0 {
+		pn.Prev = t.passageURN(refs[max(i-span, 0)], refs[i-1])
+	}
+	if j+1 < len(refs) {
+		pn.Next = t.passageURN(refs[j+1], refs[min(j+span, len(refs)-1)])
+	}
+	return pn, nil
+}
+
+func (s *server) handle(req request) (any, error) {
+	if req.Name == "GetCapabilities" {
+		groups, err := s.inventory()
+		if err != nil {
+			return nil, newError(errCommand, "%v", err)
+		}
+		var c capabilities
+		c.Request = req
+		c.Reply.Inventory.Groups = groups
+		return c, nil
+	}
+
+	switch req.Name {
+	case "GetValidReff", "GetPassage", "GetPassagePlus", "GetPrevNextUrn":
+	default:
+		return nil, newError(errInvalidRequest, "unknown request %q", req.Name)
+	}
+	if req.URN == "" {
+		return nil, newError(errInvalidParam, "%s needs a urn parameter", req.Name)
+	}
+	t, err := s.open(req.URN)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	u := t.urn
+
+	switch req.Name {
+	case "GetValidReff":
+		depth := t.depth()
+		lv := depth
+		if req.Level != "" {
+			if lv, err = strconv.Atoi(req.Level); err != nil || lv < 1 || lv > depth {
+				return nil, newError(errInvalidLevel, "level %q: %s has %d citation levels", req.Level, u, depth)
+			}
+		}
+		refs, err := t.reffs(u.From, u.To, lv)
+		if err != nil {
+			return nil, err
+		}
+		var r validReff
+		r.Request = req
+		for _, ref := range refs {
+			r.Reply.URNs = append(r.Reply.URNs, t.passageURN(ref, ref))
+		}
+		return r, nil
+
+	case "GetPassage":
+		ps, err := t.passage(u.From, u.To)
+		if err != nil {
+			return nil, err
+		}
+		return getPassage{Request: req, Reply: passageReply{URN: u.String(), Passage: ps}}, nil
+
+	case "GetPassagePlus":
+		ps, err := t.passage(u.From, u.To)
+		if err != nil {
+			return nil, err
+		}
+		pn, err := t.prevNext()
+		if err != nil {
+			return nil, err
+		}
+		_, name := s.corpus.Author(t.textID)
+		lb := &label{
+			GroupName: name,
+			Title:     t.meta.Title,
+			Citation:  u.From,
+		}
+		if u.To != u.From {
+			lb.Citation += "-" + u.To
+		}
+		return getPassagePlus{Request: req, Reply: passageReply{URN: u.String(), Label: lb, Passage: ps, PrevNext: &pn}}, nil
+
+	default: // GetPrevNextUrn
+		pn, err := t.prevNext()
+		if err != nil {
+			return nil, err
+		}
+		var r getPrevNext
+		r.Request = req
+		r.Reply.URN = u.String()
+		r.Reply.PrevNext = pn
+		return r, nil
+	}
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := request{Name: q.Get("request"), URN: q.Get("urn"), Level: q.Get("level")}
+	asJSON := q.Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+
+	resp, err := s.handle(req)
+	status := http.StatusOK
+	if err != nil {
+		cerr, ok := err.(*ctsError)
+		if !ok {
+			cerr = newError(errCommand, "%v", err)
+		}
+		resp = cerr
+		status = http.StatusBadRequest
+		if cerr.Code == errInvalidRef {
+			status = http.StatusNotFound
+		}
+	}
+
+	var body []byte
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		body, err = json.MarshalIndent(resp, "", "  ")
+	} else {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		body, err = xml.MarshalIndent(resp, "", "  ")
+		body = append([]byte(xml.Header), body...)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, s.norm.Apply(string(body))+"\n")
+}
+
+func main() {
+	dirPath := flag.String("d", ".", "TLG-E / PHI-5 directory")
+	addr := flag.String("addr", ":8080", "listen address")
+	var norm tlgcore.OutputForm
+	flag.Var(&norm, "norm", tlgcore.OutputFormUsage)
+	flag.Parse()
+
+	s := &server{corpus: tlgcore.OpenCorpus(*dirPath), norm: norm}
+	http.Handle("/api/cts", s)
+	http.Handle("/api/cts/", s)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/api/cts?request=GetCapabilities", http.StatusFound)
+	})
+
+	log.Printf("serving CTS API for %s at http://%s/api/cts", *dirPath, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"tlgread/pkg/tlgcore"
+)
+
+// countText adds the word forms of one text file, or of one of its works,
+// to forms.
+func countText(corpus *tlgcore.Corpus, textID, workID string, isLatin bool, forms *tlgcore.FreqTable) error {
+	p, err := corpus.Open(textID)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	for tok, err := range p.Tokens(workID) {
+		if err != nil {
+			return err
+		}
+		forms.Add(tlgcore.FormKey(tok.Text, isLatin), 1)
+	}
+	return nil
+}
+
+// lemmaTable credits every form's count to its lemmata in the analyses
+// file. It also returns the number of tokens left unanalyzed.
+func lemmaTable(analysesPath string, forms *tlgcore.FreqTable) (*tlgcore.FreqTable, int, error) {
+	ranked := forms.Ranked()
+	wanted := make(map[string]bool, len(ranked))
+	for _, e := range ranked {
+		wanted[e.Key] = true
+	}
+
+	lemmas, err := tlgcore.FormLemmas(analysesPath, wanted)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	table := tlgcore.NewFreqTable()
+	unknown := 0
+	for _, e := range ranked {
+		ls := lemmas[e.Key]
+		if len(ls) == 0 {
+			unknown += e.Count
+		}
+		table.AddShared(ls, e.Count)
+	}
+	return table, unknown, nil
+}
+
+func printTable(title string, t *tlgcore.FreqTable, top int, isLatin, showAmbiguous bool) {
+	fmt.Fprintf(stdout, "%s\n", title)
+	fmt.Fprintf(stdout, "tokens %d  types %d  hapax %d\n", t.Total, t.Types(), t.Hapax())
+
+	if showAmbiguous {
+		fmt.Fprintf(stdout, "%6s %8s %9s %9s  %s\n", "rank", "count", "per10k", "ambiguous", "lemma")
+	} else {
+		fmt.Fprintf(stdout, "%6s %8s %9s  %s\n", "rank", "count", "per10k", "form")
+	}
+	for i, e := range t.Ranked() {
+		if top > 0 && i >= top {
+			break
+		}
+		word := e.Key
+		if !isLatin {
+			word = tlgcore.ToGreek(word)
+		}
+		if showAmbiguous {
+			fmt.Fprintf(stdout, "%6d %8d %9.2f %9d  %s\n", i+1, e.Count, t.Rate(e.Count), e.Ambiguous, word)
+		} else {
+			fmt.Fprintf(stdout, "%6d %8d %9.2f  %s\n", i+1, e.Count, t.Rate(e.Count), word)
+		}
+	}
+}
+
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
+
+func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	dirPath := flag.String("d", ".", "corpus root containing tlg*.txt / lat*.txt")
+	author := flag.String("author", "", "author file, e.g. tlg0012 (default: whole corpus)")
+	workID := flag.String("w", "", "work ID within -author (default: all works)")
+	isLatin := flag.Bool("lat", false, "count Latin (PHI) texts")
+	kind := flag.String("kind", "", tlgcore.TextKindUsage)
+	analysesPath := flag.String("a", "", "greek-analyses.txt (latin-analyses.txt with -lat) for lemma frequencies")
+	top := flag.Int("n", 100, "rows to print per table (0 for all)")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
+	flag.Parse()
+
+	if *workID != "" && *author == "" {
+		return errors.New("Usage: freq -d corpus [-author tlgNNNN [-w work]] [-a greek-analyses.txt] [-n rows]")
+	}
+	wID := tlgcore.NormalizeID(*workID)
+
+	corpus := tlgcore.OpenCorpus(*dirPath)
+	forms := tlgcore.NewFreqTable()
+
+	var scope string
+	if *author != "" {
+		if err := countText(corpus, *author, wID, *isLatin, forms); err != nil {
+			return err
+		}
+
+		id, name := corpus.Author(*author)
+		scope = fmt.Sprintf("%s (%s)", name, id)
+		if wID != "" {
+			scope += fmt.Sprintf(", %s: %s", wID, corpus.Title(*author, wID))
+		}
+	} else {
+		kinds, err := tlgcore.ParseTextKinds(*kind, *isLatin)
+		if err != nil {
+			return err
+		}
+		texts, err := corpus.Texts(kinds...)
+		if err != nil {
+			return err
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("no text files found under %s", *dirPath)
+		}
+		for _, textID := range texts {
+			if err := countText(corpus, textID, "", *isLatin, forms); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", textID, err)
+			}
+		}
+		scope = fmt.Sprintf("%s (%d files)", *dirPath, len(texts))
+	}
+	if forms.Total == 0 {
+		return fmt.Errorf("no words found in %s", scope)
+	}
+
+	printTable("Word forms: "+scope, forms, *top, *isLatin, false)
+
+	if *analysesPath != "" {
+		lemmas, unknown, err := lemmaTable(*analysesPath, forms)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout)
+		printTable("Lemmata: "+scope, lemmas, *top, *isLatin, true)
+		fmt.Fprintf(stdout, "unanalyzed tokens %d (%.2f per 10k)\n", unknown, lemmas.Rate(unknown))
+	}
+	return nil
+}
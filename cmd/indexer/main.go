@@ -14,11 +14,22 @@ func main() {
 
 	xPath := flag.String("f", "grc.lsj.xml", "file path for dictionary xml file")
 	iPath := flag.String("o", "lsj.idt", "file path for export index file")
+	corpusDir := flag.String("corpus", "", "index the TLG/PHI texts under this directory instead of a dictionary")
+	isLatin := flag.Bool("lat", false, "with -corpus, index Latin (PHI) texts")
+	kind := flag.String("kind", "", "with -corpus, "+tlgcore.TextKindUsage)
 	flag.Parse()
 
 	xmlPath := *xPath
 	indexPath := *iPath
 
+	if *corpusDir != "" {
+		if err := indexCorpus(*corpusDir, *kind, *isLatin, indexPath); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	f, err := os.Open(xmlPath)
 	if err != nil {
 		fmt.Println("Error: Cannot find grc.lsj.xml")
@@ -68,3 +79,45 @@ func main() {
 	}
 	fmt.Println("Done!", indexPath, "created.")
 }
+
+func indexCorpus(dir, kind string, isLatin bool, indexPath string) error {
+	kinds, err := tlgcore.ParseTextKinds(kind, isLatin)
+	if err != nil {
+		return err
+	}
+	corpus := tlgcore.OpenCorpus(dir)
+	texts, err := corpus.Texts(kinds...)
+	if err != nil {
+		return err
+	}
+	if len(texts) == 0 {
+		return fmt.Errorf("no text files found under %s", dir)
+	}
+
+	fmt.Println("Indexing", len(texts), "texts in", dir, "... this may take a while.")
+
+	b := tlgcore.NewIndexBuilder()
+	for i, textID := range texts {
+		if err := b.AddText(corpus, textID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", textID, err)
+		}
+		if (i+1)%100 == 0 {
+			fmt.Printf("  %d/%d\n", i+1, len(texts))
+		}
+	}
+
+	out, err := os.Create(indexPath)
+	if err != nil {
+		return err
+	}
+	if _, err := b.WriteTo(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	fmt.Println("Done!", indexPath, "created.")
+	return nil
+}
@@ -1,93 +1,74 @@
 package main
 
 import (
-	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"log"
 	"os"
-	"strings"
 	"tlgread/pkg/tlgcore"
 )
 
-type LemmaInfo struct {
-	Lemma string
-	Forms []string
+// lemmaJSON is the -json output: the lemma and its forms, in Greek for
+// the Greek lemmata.
+type lemmaJSON struct {
+	Lemma string              `json:"lemma"`
+	Forms []tlgcore.LemmaForm `json:"forms"`
 }
 
-func findForms(filePath, targetLemma string) (*LemmaInfo, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "\t")
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
 
-		if len(parts) < 3 {
-			continue
-		}
-
-		lemma := strings.TrimSpace(parts[0])
-		if lemma == targetLemma {
-			allForms := parts[2:]
-			return &LemmaInfo{
-				Lemma: lemma,
-				Forms: allForms,
-			}, nil
-		}
+func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	return nil, fmt.Errorf("lemma %s not found", targetLemma)
 }
 
-func main() {
-
+func run() error {
 	fPath := flag.String("f", "greek-lemmata.txt", "file path for greek-lemmata.txt")
 	word := flag.String("w", "", "word")
 	isLatin := flag.Bool("l", false, "Search for latin words")
+	asJSON := flag.Bool("json", false, "print the lemma and its forms as JSON")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
 	flag.Parse()
 
 	filePath := *fPath
 
-	searchWord := *word
-	for _, r := range *word {
-		if r > 127 { // Simple check for non-ASCII
-			searchWord = tlgcore.ToBetaCode(*word)
-			break
-		}
-	}
-
-	searchWord = tlgcore.NormalizeBetaCode(searchWord)
+	searchWord := tlgcore.LemmaQuery(*word)
 
-	info, err := findForms(filePath, searchWord)
+	info, err := tlgcore.FindForms(filePath, searchWord)
 	if err != nil {
-		fmt.Println(err)
-		return
+		if *asJSON {
+			return err
+		}
+		fmt.Fprintln(stdout, err)
+		return nil
 	}
 
+	out := lemmaJSON{Lemma: info.Lemma, Forms: info.Inflections()}
 	if !*isLatin {
-		fmt.Printf("Lemma: %s\n", tlgcore.ToGreek(info.Lemma))
-	} else {
-		fmt.Printf("Lemma: %s\n", info.Lemma)
+		out.Lemma = tlgcore.ToGreek(out.Lemma)
+		for i := range out.Forms {
+			out.Forms[i].Form = tlgcore.ToGreek(out.Forms[i].Form)
+		}
 	}
-	fmt.Println("Known inflections and variants:")
-	for _, f := range info.Forms {
-		if f != "" {
-			form := strings.Split(f, " ")
-			analysis := strings.Join(form[1:], " ")
-			if !*isLatin {
-				fmt.Printf(" - %s %s\n", tlgcore.ToGreek(form[0]), strings.TrimSpace(analysis))
-			} else {
-				fmt.Printf(" - %s %s\n", form[0], strings.TrimSpace(analysis))
-			}
+
+	if *asJSON {
+		if out.Forms == nil {
+			out.Forms = []tlgcore.LemmaForm{}
 		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	fmt.Fprintf(stdout, "Lemma: %s\n", out.Lemma)
+	fmt.Fprintln(stdout, "Known inflections and variants:")
+	for _, f := range out.Forms {
+		fmt.Fprintf(stdout, " - %s %s\n", f.Form, f.Analysis)
 	}
+	return nil
 }
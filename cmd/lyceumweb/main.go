@@ -0,0 +1,292 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"tlgread/pkg/tlgcore"
+)
+
+//go:embed static
+var static embed.FS
+
+// dictionary is the analyses file and lexicon of one language, with their
+// indexes loaded on first lookup.
+type dictionary struct {
+	analyses, analysesIdt string
+	lexicon, lexiconIdt   string
+	greek                 bool
+
+	once     sync.Once
+	index    map[string]int64
+	keys     []string
+	lexIndex map[string]int64
+	err      error
+}
+
+func (d *dictionary) load() error {
+	d.once.Do(func() {
+		d.index, d.keys, d.err = tlgcore.LoadIndex(d.analysesIdt)
+		if d.err != nil {
+			d.err = fmt.Errorf("loading analyses index: %v", d.err)
+			return
+		}
+		var err error
+		if d.lexIndex, err = tlgcore.LoadDictIndex(d.lexiconIdt); err != nil {
+			log.Printf("Warning: Could not open index file at %s", d.lexiconIdt)
+		}
+	})
+	return d.err
+}
+
+type lookupReply struct {
+	Word     string                `json:"word"`
+	Analyses []tlgcore.MorphResult `json:"analyses"`
+	Entries  []tlgcore.DictEntry   `json:"entries"`
+}
+
+// lookup analyzes a word and collects the lexicon entries of its lemmata,
+// as cmd/search does.
+func (d *dictionary) lookup(word string) (lookupReply, error) {
+	r := lookupReply{Word: word, Analyses: []tlgcore.MorphResult{}, Entries: []tlgcore.DictEntry{}}
+	if err := d.load(); err != nil {
+		return r, err
+	}
+	results, err := tlgcore.AnalyzeWord(d.analyses, d.index, d.keys, word)
+	if err != nil {
+		return r, nil
+	}
+
+	seen := make(map[int64]bool)
+	for _, m := range results {
+		entries, err := tlgcore.LookupDict(d.lexicon, m.Lemma, d.lexIndex, seen, d.greek)
+		r.Entries = append(r.Entries, entries...)
+		if err != nil {
+			return r, err
+		}
+		m.Lemma = strings.Fields(m.Lemma)[0]
+		if d.greek {
+			m = m.Greek()
+		}
+		r.Analyses = append(r.Analyses, m)
+	}
+	return r, nil
+}
+
+type author struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Lang string `json:"lang"`
+}
+
+type textPage struct {
+	Author string              `json:"author"`
+	Work   string              `json:"work"`
+	Title  string              `json:"title"`
+	Lang   string              `json:"lang"`
+	Page   int                 `json:"page"`
+	Pages  int                 `json:"pages"`
+	Lines  []tlgcore.CitedLine `json:"lines"`
+}
+
+type server struct {
+	corpus   *tlgcore.Corpus
+	pageSize int
+	norm     tlgcore.OutputForm
+	greek    *dictionary
+	latin    *dictionary
+
+	once    sync.Once
+	authors []author
+	err     error
+}
+
+func lang(textID string) string {
+	switch {
+	case tlgcore.IsLatinFileName(path.Base(textID)):
+		return "la"
+	case tlgcore.IsCopticFileName(path.Base(textID)):
+		return "cop"
+	}
+	return "grc"
+}
+
+// authorList lists the text files of the corpus, named from authtab.dir.
+// It is built on first use and kept.
+func (s *server) authorList() ([]author, error) {
+	s.once.Do(func() {
+		s.authors = []author{}
+		texts, err := s.corpus.Texts()
+		if err != nil {
+			s.err = err
+			return
+		}
+		for _, textID := range texts {
+			_, name := s.corpus.Author(textID)
+			s.authors = append(s.authors, author{ID: textID, Name: name, Lang: lang(textID)})
+		}
+	})
+	return s.authors, s.err
+}
+
+func (s *server) works(textID string) ([]*tlgcore.WorkMetadata, error) {
+	idt, err := s.corpus.IDT(textID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(idt))
+	for id := range idt {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		na, _ := strconv.Atoi(a)
+		nb, _ := strconv.Atoi(b)
+		return na - nb
+	})
+
+	ws := []*tlgcore.WorkMetadata{}
+	for _, id := range ids {
+		ws = append(ws, idt[id])
+	}
+	return ws, nil
+}
+
+// text returns one page of a work; pages are counted from 1.
+func (s *server) text(textID, workID string, page int) (textPage, error) {
+	t := textPage{Author: textID, Work: workID, Lang: lang(textID), Page: page, Lines: []tlgcore.CitedLine{}}
+	p, err := s.corpus.Open(textID)
+	if err != nil {
+		return t, err
+	}
+	defer p.Close()
+	meta := p.IDTData[workID]
+	if meta != nil {
+		t.Title = meta.Title
+	}
+
+	first := (page - 1) * s.pageSize
+	n := 0
+	for line, err := range p.Lines(workID) {
+		if err != nil {
+			return t, err
+		}
+		if n >= first && n < first+s.pageSize {
+			t.Lines = append(t.Lines, line.Cited(meta))
+		}
+		n++
+	}
+	if n == 0 {
+		return t, fmt.Errorf("work ID %s not found: %w", workID, fs.ErrNotExist)
+	}
+	t.Pages = (n + s.pageSize - 1) / s.pageSize
+	return t, nil
+}
+
+// writeJSON replies with v, or with err, in the Unicode form chosen with
+// -norm.
+func (s *server) writeJSON(w http.ResponseWriter, v any, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, fs.ErrNotExist) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		v = map[string]string{"error": err.Error()}
+	}
+	fw := tlgcore.NewFormWriter(w, s.norm)
+	json.NewEncoder(fw).Encode(v)
+	fw.Flush()
+}
+
+func (s *server) handleAuthors(w http.ResponseWriter, r *http.Request) {
+	authors, err := s.authorList()
+	s.writeJSON(w, authors, err)
+}
+
+func (s *server) handleWorks(w http.ResponseWriter, r *http.Request) {
+	textID, err := s.corpus.FindText(r.URL.Query().Get("author"))
+	if err != nil {
+		s.writeJSON(w, nil, err)
+		return
+	}
+	ws, err := s.works(textID)
+	s.writeJSON(w, ws, err)
+}
+
+func (s *server) handleText(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	textID, err := s.corpus.FindText(q.Get("author"))
+	if err != nil {
+		s.writeJSON(w, nil, err)
+		return
+	}
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	t, err := s.text(textID, tlgcore.NormalizeID(q.Get("work")), page)
+	s.writeJSON(w, t, err)
+}
+
+func (s *server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	d := s.greek
+	if q.Get("lang") == "la" {
+		d = s.latin
+	}
+	reply, err := d.lookup(strings.TrimSpace(q.Get("word")))
+	s.writeJSON(w, reply, err)
+}
+
+func main() {
+	dirPath := flag.String("d", ".", "TLG-E / PHI-5 directory")
+	addr := flag.String("addr", ":8080", "listen address")
+	pageSize := flag.Int("page", 100, "lines per page")
+	grAnal := flag.String("a", "greek-analyses.txt", "Greek analyses txt file")
+	grIdt := flag.String("idt", "greek-analyses.idt", "Greek analyses idt file")
+	lsj := flag.String("dic", "grc.lsj.xml", "LSJ XML path")
+	lsjIdt := flag.String("dicidt", "lsj.idt", "LSJ idt file")
+	laAnal := flag.String("la", "latin-analyses.txt", "Latin analyses txt file")
+	laIdt := flag.String("laidt", "latin-analyses.idt", "Latin analyses idt file")
+	ls := flag.String("ls", "lat.ls.perseus-eng1.xml", "L-S XML path")
+	lsIdt := flag.String("lsidt", "ls.idt", "L-S idt file")
+	var norm tlgcore.OutputForm
+	flag.Var(&norm, "norm", tlgcore.OutputFormUsage)
+	flag.Parse()
+
+	if *pageSize < 1 {
+		log.Fatal("-page must be at least 1")
+	}
+
+	s := &server{
+		corpus:   tlgcore.OpenCorpus(*dirPath),
+		pageSize: *pageSize,
+		norm:     norm,
+		greek:    &dictionary{analyses: *grAnal, analysesIdt: *grIdt, lexicon: *lsj, lexiconIdt: *lsjIdt, greek: true},
+		latin:    &dictionary{analyses: *laAnal, analysesIdt: *laIdt, lexicon: *ls, lexiconIdt: *lsIdt},
+	}
+
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/", http.FileServerFS(assets))
+	http.HandleFunc("/api/authors", s.handleAuthors)
+	http.HandleFunc("/api/works", s.handleWorks)
+	http.HandleFunc("/api/text", s.handleText)
+	http.HandleFunc("/api/lookup", s.handleLookup)
+
+	log.Printf("serving %s at http://%s/", *dirPath, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
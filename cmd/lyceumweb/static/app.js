@@ -0,0 +1,161 @@
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+const state = { author: null, work: null, lang: "grc", page: 1, pages: 1 };
+
+async function get(url) {
+	const resp = await fetch(url);
+	const data = await resp.json();
+	if (!resp.ok) {
+		throw new Error(data.error || resp.statusText);
+	}
+	return data;
+}
+
+function el(tag, props, ...children) {
+	const e = document.createElement(tag);
+	Object.assign(e, props);
+	e.append(...children);
+	return e;
+}
+
+function showError(target, err) {
+	target.replaceChildren(el("p", { className: "error", textContent: err.message }));
+}
+
+async function loadAuthors() {
+	const authors = await get("/api/authors");
+	const list = $("authors");
+	for (const a of authors) {
+		const li = el("li", { textContent: a.name, title: a.id });
+		li.dataset.search = (a.name + " " + a.id).toLowerCase();
+		li.onclick = () => {
+			for (const c of list.children) {
+				c.classList.toggle("current", c === li);
+			}
+			loadWorks(a);
+		};
+		list.append(li);
+	}
+	$("filter").oninput = (ev) => {
+		const q = ev.target.value.toLowerCase();
+		for (const li of list.children) {
+			li.hidden = !li.dataset.search.includes(q);
+		}
+	};
+}
+
+async function loadWorks(a) {
+	state.author = a.id;
+	state.lang = a.lang;
+	$("heading").textContent = a.name;
+	$("text").replaceChildren();
+	$("pager").hidden = true;
+	const list = $("works");
+	list.replaceChildren();
+	try {
+		for (const w of await get("/api/works?author=" + encodeURIComponent(a.id))) {
+			const labels = [...new Set((w.citations || []).map((c) => c.label))];
+			const cit = labels.length ? " (" + labels.join(", ") + ")" : "";
+			list.append(el("li", {
+				textContent: w.id + ". " + w.title + cit,
+				onclick: () => loadText(w.id, 1),
+			}));
+		}
+	} catch (err) {
+		showError(list, err);
+	}
+}
+
+// words wraps the words of a line in spans; the pattern keeps elision
+// marks with the word, as the tokenizer does.
+function words(text) {
+	const out = [];
+	let last = 0;
+	for (const m of text.matchAll(/[\p{L}\p{M}]+[’'ʼ]?/gu)) {
+		out.push(text.slice(last, m.index));
+		out.push(el("span", { className: "w", textContent: m[0] }));
+		last = m.index + m[0].length;
+	}
+	out.push(text.slice(last));
+	return out;
+}
+
+// joinHyphenated gives a word hyphenated at the end of a line and its
+// continuation on the next line the whole word to look up, as the
+// tokenizer joins them.
+function joinHyphenated(rows) {
+	for (let i = 0; i + 1 < rows.length; i++) {
+		const cell = rows[i].lastChild;
+		const last = cell.querySelector("span.w:last-of-type");
+		const next = rows[i + 1].lastChild.querySelector("span.w");
+		if (last && next && /^[\s()[\]{}]*[-‐][\s()[\]{}]*$/.test(cell.lastChild.textContent)) {
+			last.dataset.word = next.dataset.word = last.textContent + next.textContent;
+		}
+	}
+}
+
+async function loadText(work, page) {
+	state.work = work;
+	let t;
+	try {
+		t = await get("/api/text?author=" + encodeURIComponent(state.author) +
+			"&work=" + encodeURIComponent(work) + "&page=" + page);
+	} catch (err) {
+		showError($("works"), err);
+		return;
+	}
+	state.page = t.page;
+	state.pages = t.pages;
+	$("heading").textContent = $("heading").textContent.split(" — ")[0] + " — " + t.title;
+	$("works").replaceChildren();
+	$("pager").hidden = false;
+	$("pageinfo").textContent = t.page + " / " + t.pages;
+	$("prev").disabled = t.page <= 1;
+	$("next").disabled = t.page >= t.pages;
+
+	const rows = t.lines.map((l) => el("tr", {},
+		el("td", { className: "cit", textContent: l.citation }),
+		el("td", { lang: t.lang }, ...words(l.text))));
+	joinHyphenated(rows);
+	$("text").replaceChildren(...rows);
+	$("main").scrollTop = 0;
+}
+
+async function lookup(span) {
+	for (const c of document.querySelectorAll("span.w.current")) {
+		c.classList.remove("current");
+	}
+	span.classList.add("current");
+
+	const word = (span.dataset.word || span.textContent).replace(/[’'ʼ]$/, "");
+	const panel = $("panel");
+	panel.replaceChildren(el("h2", { textContent: word }));
+	let r;
+	try {
+		r = await get("/api/lookup?lang=" + state.lang + "&word=" + encodeURIComponent(word));
+	} catch (err) {
+		panel.append(el("p", { className: "error", textContent: err.message }));
+		return;
+	}
+	if (r.analyses.length === 0) {
+		panel.append(el("p", { className: "hint", textContent: "No analysis found." }));
+		return;
+	}
+	panel.append(el("ul", {}, ...r.analyses.map((a) =>
+		el("li", {}, el("b", { textContent: a.lemma }), " " + a.form + " (" + a.morphology + ")"))));
+	for (const e of r.entries) {
+		panel.append(el("h3", { textContent: e.headword }), el("div", { className: "entry", textContent: e.sense }));
+	}
+}
+
+$("prev").onclick = () => loadText(state.work, state.page - 1);
+$("next").onclick = () => loadText(state.work, state.page + 1);
+$("text").onclick = (ev) => {
+	if (ev.target.classList.contains("w")) {
+		lookup(ev.target);
+	}
+};
+
+loadAuthors().catch((err) => showError($("authors"), err));
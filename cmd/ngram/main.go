@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"tlgread/pkg/tlgcore"
+)
+
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
+
+func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	dirPath := flag.String("d", ".", "corpus root containing tlg*.txt / lat*.txt")
+	authors := flag.String("author", "", "comma-separated author files, e.g. tlg0012,tlg0013 (default: whole corpus)")
+	workID := flag.String("w", "", "work ID within a single -author (default: all works)")
+	isLatin := flag.Bool("lat", false, "use Latin (PHI) texts")
+	kind := flag.String("kind", "", tlgcore.TextKindUsage)
+	n := flag.Int("n", 3, "words per n-gram (2-6)")
+	minCount := flag.Int("min", 2, "least occurrences to report")
+	top := flag.Int("top", 50, "n-grams to print (0 for all)")
+	examples := flag.Int("examples", 3, "example citations per n-gram")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
+	flag.Parse()
+
+	if *n < 2 || *n > 6 {
+		return errors.New("-n must be between 2 and 6")
+	}
+	var texts []string
+	if *authors != "" {
+		texts = strings.Split(*authors, ",")
+	}
+	if *workID != "" && len(texts) != 1 {
+		return errors.New("Usage: ngram -d corpus [-author tlgNNNN[,tlgNNNN...] | -author tlgNNNN -w work] [-n 3]")
+	}
+
+	corpus := tlgcore.OpenCorpus(*dirPath)
+	if texts == nil {
+		kinds, err := tlgcore.ParseTextKinds(*kind, *isLatin)
+		if err != nil {
+			return err
+		}
+		if texts, err = corpus.Texts(kinds...); err != nil {
+			return err
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("no text files found under %s", *dirPath)
+		}
+	}
+
+	counter := tlgcore.NewNGramCounter(*n, *examples)
+	for _, textID := range texts {
+		p, err := corpus.Open(strings.TrimSpace(textID))
+		if err != nil {
+			return err
+		}
+		for tok, err := range p.Tokens(*workID) {
+			if err != nil {
+				return fmt.Errorf("%s: %v", textID, err)
+			}
+			counter.Add(textID, tok)
+		}
+		p.Close()
+	}
+
+	grams := counter.Top(*minCount)
+	fmt.Fprintf(stdout, "%d-grams occurring at least %d times: %d\n", *n, *minCount, len(grams))
+	for i, g := range grams {
+		if *top > 0 && i >= *top {
+			break
+		}
+		gram := strings.Join(g.Keys, " ")
+		switch {
+		case *isLatin:
+		case len(g.Examples) > 0 && tlgcore.IsCopticFileName(path.Base(g.Examples[0].TextID)):
+			gram = tlgcore.ToCoptic(gram)
+		default:
+			gram = tlgcore.ToGreek(gram)
+		}
+		fmt.Fprintf(stdout, "%6d %8d  %s\n", i+1, g.Count, gram)
+		for _, ex := range g.Examples {
+			_, name := corpus.Author(ex.TextID)
+			fmt.Fprintf(stdout, "%16s%s, %s %s: %s\n", "", name, corpus.Title(ex.TextID, ex.WorkID), ex.Citation, ex.Text)
+		}
+	}
+	return nil
+}
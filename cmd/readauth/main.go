@@ -1,25 +1,56 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"tlgread/pkg/tlgcore"
 )
 
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
+
 func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
 	fPath := flag.String("f", "authtab.dir", "filename")
+	asJSON := flag.Bool("json", false, "print the records as JSON")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
 	flag.Parse()
 
 	records, err := tlgcore.ReadAuthorTable(*fPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
+	out := []tlgcore.AuthorRecord{}
 	for _, r := range records {
 		if len(r.ID) > 0 && r.ID[0] != '*' {
-			fmt.Printf("%-8s | %s\n", r.ID, r.Name)
+			out = append(out, r)
+		}
+	}
+
+	if *asJSON {
+		for i := range out {
+			out[i].ID = strings.TrimSpace(out[i].ID)
 		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	for _, r := range out {
+		fmt.Fprintf(stdout, "%-8s | %s\n", r.ID, r.Name)
 	}
+	return nil
 }
@@ -1,252 +1,59 @@
 package main
 
 import (
-	"bufio"
-	"encoding/xml"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"regexp"
-	"sort"
-	"strconv"
 	"strings"
 	"tlgread/pkg/tlgcore"
 )
 
-type MorphResult struct {
-	Form       string
-	Lemma      string
-	ShortDef   string
-	Morphology string
+// lookupJSON is the -json output: the analyses of the word, with Greek
+// forms and lemmata decoded, and the dictionary entries of their lemmata.
+type lookupJSON struct {
+	Word     string                `json:"word"`
+	Analyses []tlgcore.MorphResult `json:"analyses"`
+	Entries  []tlgcore.DictEntry   `json:"entries"`
 }
 
-type LSJEntry struct {
-	Key   string `xml:"key,attr"`
-	Orth  string `xml:"orth"`
-	Sense string `xml:",innerxml"`
-}
-
-func LoadIndex(idtPath string) (map[string]int64, []string, error) {
-	index := make(map[string]int64)
-	var keys []string
-	file, err := os.Open(idtPath)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer file.Close()
-
-	re := regexp.MustCompile(`'(.+?)' => (\d+)`)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		matches := re.FindStringSubmatch(scanner.Text())
-		if len(matches) == 3 {
-			offset, _ := strconv.ParseInt(matches[2], 10, 64)
-			key := matches[1]
-			index[key] = offset
-			keys = append(keys, key)
-		}
-	}
-	sort.Strings(keys)
-	return index, keys, nil
-}
-
-func FindLemmaIndexed(filePath string, offset int64, searchForm string) ([]MorphResult, error) {
-	file, err := os.Open(filePath)
+func LoadLSJIndex(path string) map[string]int64 {
+	index, err := tlgcore.LoadDictIndex(path)
 	if err != nil {
-		return nil, err
+		fmt.Fprintf(os.Stderr, "Warning: Could not open index file at %s\n", path)
 	}
-	defer file.Close()
-
-	file.Seek(offset, 0)
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 1024*1024)
-	scanner.Buffer(buf, 1024*1024)
-
-	re := regexp.MustCompile(`\{[^ ]+ \d+ (?:[^,]+,)?(?P<lemma>[^ ]+)(?P<content>.*?)\}`)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
-		currentWord := strings.TrimPrefix(fields[0], "!")
-
-		if strings.EqualFold(currentWord, searchForm) {
-			var results []MorphResult
-			matches := re.FindAllStringSubmatch(line, -1)
-			for _, match := range matches {
-				parts := regexp.MustCompile(`\s{2,}`).Split(strings.TrimSpace(match[2]), -1)
-
-				resDef := "---"
-				resMorph := ""
-				if len(parts) >= 2 {
-					resDef = strings.TrimSpace(parts[0])
-					resMorph = strings.TrimSpace(parts[1])
-				} else if len(parts) == 1 {
-					resMorph = strings.TrimSpace(parts[0])
-				}
-
-				results = append(results, MorphResult{
-					Form:       searchForm,
-					Lemma:      strings.TrimSpace(match[1]), // Final fix for trailing spaces
-					ShortDef:   resDef,
-					Morphology: resMorph,
-				})
-			}
-			return results, nil
-		}
-		if len(currentWord) > 0 && currentWord[0] > searchForm[0] {
-			break
-		}
-	}
-	return nil, fmt.Errorf("not found")
+	return index
 }
 
 func lookupLSJ(xmlPath string, rawLemma string, lsjIndex map[string]int64, seenOffsets map[int64]bool, isLSJ bool) {
-	var strictKey string
-
-	lemma := strings.Fields(rawLemma)[0]
-
-	if isLSJ {
-		strictKey = tlgcore.NormalizeStrict(lemma)
-	} else {
-		strictKey = tlgcore.NormalizeLatin(lemma)
-	}
-
-	fuzzyKey := tlgcore.NormalizeFuzzy(lemma)
-
-	var offsets []int64
-	localSeen := make(map[int64]bool)
-
-	addUnique := func(val int64) {
-		if !localSeen[val] {
-			offsets = append(offsets, val)
-			localSeen[val] = true
-		}
-	}
-
-	if val, ok := lsjIndex[strictKey]; ok {
-		addUnique(val)
-	}
-
-	// Check numbered keys (e.g., "legw2", "legw3", ...) ... Do we need this?
-	for i := 2; ; i++ {
-		key := strictKey + strconv.Itoa(i)
-		val, ok := lsjIndex[key]
-		if !ok {
-			break
-		}
-		addUnique(val)
-	}
-
-	if len(offsets) == 0 {
-		if val, ok := lsjIndex[fuzzyKey]; ok {
-			addUnique(val)
-		} else {
-			for k, off := range lsjIndex {
-				if strings.HasPrefix(k, fuzzyKey) {
-					addUnique(off)
-					break
-				}
-			}
-		}
-	}
-
-	if len(offsets) > 0 {
-		f, err := os.Open(xmlPath)
-		if err != nil {
-			fmt.Println("Error opening LSJ file:", err)
-			return
-		}
-		defer f.Close()
-
-		for _, offset := range offsets {
-			if seenOffsets[offset] {
-				continue
-			}
-
-			_, err = f.Seek(offset, 0)
-			if err != nil {
-				fmt.Println("Seek error:", err)
-				return
-			}
-
-			decoder := xml.NewDecoder(f)
-			var entry LSJEntry
-			err = decoder.Decode(&entry)
-			if err != nil {
-				return
-			}
-
-			seenOffsets[offset] = true
-
-			if isLSJ {
-				fmt.Printf("\n[ENTRY: %s]\n", tlgcore.ToGreek(entry.Key))
-			} else {
-				fmt.Printf("\n[ENTRY: %s]\n", entry.Key)
-			}
-			fmt.Printf("%s\n", processSense(entry.Sense))
+	entries, err := tlgcore.LookupDict(xmlPath, rawLemma, lsjIndex, seenOffsets, isLSJ)
+	for _, e := range entries {
+		key := e.Key
+		if isLSJ {
+			key = tlgcore.ToGreek(e.Key)
 		}
+		fmt.Fprintf(stdout, "\n[ENTRY: %s]\n", key)
+		fmt.Fprintf(stdout, "%s\n", e.Sense)
 	}
-}
-
-func LoadLSJIndex(path string) map[string]int64 {
-	index := make(map[string]int64)
-	f, err := os.Open(path)
 	if err != nil {
-		fmt.Printf("Warning: Could not open index file at %s\n", path)
-		return index
-	}
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, " => ")
-		if len(parts) == 2 {
-			key := strings.Trim(parts[0], "'")
-			offset, _ := strconv.ParseInt(parts[1], 10, 64)
-			index[key] = offset
-		}
+		fmt.Fprintln(stdout, "Error:", err)
 	}
-	return index
 }
 
-func processSense(rawXml string) string {
-	reForeign := regexp.MustCompile(`<foreign lang="greek">([^<]+)</foreign>`)
-	processed := reForeign.ReplaceAllStringFunc(rawXml, func(match string) string {
-		code := reForeign.FindStringSubmatch(match)[1]
-		return tlgcore.ToGreek(code)
-	})
-
-	processed = strings.ReplaceAll(processed, "<sense", "\n\n  • <sense")
-	processed = strings.ReplaceAll(processed, "</bibl>", " ")
-	processed = strings.ReplaceAll(processed, "</cit>", " ")
-
-	stripTags := regexp.MustCompile("<[^>]*>")
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
 
-	clean := stripTags.ReplaceAllString(processed, "")
-	clean = strings.ReplaceAll(clean, "&gt;", ">")
-	clean = strings.ReplaceAll(clean, "&lt;", "<")
-	clean = strings.ReplaceAll(clean, "&amp;", "&")
-	clean = strings.ReplaceAll(clean, "&quot;", "\"")
-
-	lines := strings.Split(clean, "\n")
-	var finalLines []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" {
-			reSpace := regexp.MustCompile(`\s+`)
-			finalLines = append(finalLines, reSpace.ReplaceAllString(trimmed, " "))
-		}
+func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	return strings.Join(finalLines, "\n\n")
 }
 
-func main() {
+func run() error {
 	wordRaw := flag.String("w", "", "word in Beta Code / Greek")
 	lsjPath := flag.String("dic", "grc.lsj.xml", "LSJ XML path")
 	idtPath := flag.String("idt", "greek-analyses.idt", "idt file")
@@ -254,55 +61,44 @@ func main() {
 	lsjidtPath := flag.String("dicidt", "lsj.idt", "LSJ idt file")
 	printdic := flag.Bool("entry", true, "print dictionary entries or not")
 	isLatin := flag.Bool("lat", false, "use L-S dictionary")
+	asJSON := flag.Bool("json", false, "print analyses and entries as JSON")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
 
 	flag.Parse()
 
 	lsjIndex := LoadLSJIndex(*lsjidtPath)
 
-	searchWord := *wordRaw
-	for _, r := range *wordRaw {
-		if r > 127 {
-			searchWord = tlgcore.ToBetaCode(*wordRaw)
-			break
-		}
-	}
-
-	searchWord = tlgcore.NormalizeBetaCode(searchWord)
-
-	index, keys, err := LoadIndex(*idtPath)
+	index, keys, err := tlgcore.LoadIndex(*idtPath)
 	if err != nil {
-		log.Fatalf("Failed to load index: %v", err)
+		return fmt.Errorf("Failed to load index: %v", err)
 	}
 
-	performSearch := func(query string) ([]MorphResult, error) {
-		idx := sort.SearchStrings(keys, query)
-		if idx > 0 {
-			idx -= 1
-		}
+	results, err := tlgcore.AnalyzeWord(*analPath, index, keys, *wordRaw)
 
-		var res []MorphResult
-		var e error
-		for i := range 3 {
-			if idx-i < 0 {
-				break
+	if *asJSON {
+		out := lookupJSON{Word: *wordRaw, Analyses: []tlgcore.MorphResult{}, Entries: []tlgcore.DictEntry{}}
+		seen := make(map[int64]bool)
+		for _, r := range results {
+			if *printdic {
+				entries, err := tlgcore.LookupDict(*lsjPath, r.Lemma, lsjIndex, seen, !*isLatin)
+				if err != nil {
+					return err
+				}
+				out.Entries = append(out.Entries, entries...)
 			}
-			res, e = FindLemmaIndexed(*analPath, index[keys[idx-i]], query)
-			if e == nil {
-				return res, nil
+			r.Lemma = strings.Fields(r.Lemma)[0]
+			if !*isLatin {
+				r = r.Greek()
 			}
+			out.Analyses = append(out.Analyses, r)
 		}
-		return nil, fmt.Errorf("not found")
-	}
-
-	results, err := performSearch(searchWord)
-
-	if err != nil && strings.Contains(searchWord, "*") {
-		lowerWord := tlgcore.BetaToLower(searchWord)
-		results, err = performSearch(lowerWord)
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
 	}
 
 	if err != nil {
-		log.Fatal("Morphology not found.")
+		return errors.New("Morphology not found.")
 	}
 
 	seenLSJEntries := make(map[int64]bool)
@@ -311,9 +107,9 @@ func main() {
 		// 1. Print Morphology
 		lemmaDisplay := strings.Fields(r.Lemma)[0]
 		if !*isLatin {
-			fmt.Printf("Greek: %s | Lemma: %s (%s)\n", tlgcore.ToGreek(r.Form), tlgcore.ToGreek(lemmaDisplay), r.Morphology)
+			fmt.Fprintf(stdout, "Greek: %s | Lemma: %s (%s)\n", tlgcore.ToGreek(r.Form), tlgcore.ToGreek(lemmaDisplay), r.Morphology)
 		} else {
-			fmt.Printf("Latin: %s | Lemma: %s (%s)\n", r.Form, lemmaDisplay, r.Morphology)
+			fmt.Fprintf(stdout, "Latin: %s | Lemma: %s (%s)\n", r.Form, lemmaDisplay, r.Morphology)
 		}
 	}
 	if *printdic == true {
@@ -321,4 +117,5 @@ func main() {
 			lookupLSJ(*lsjPath, r.Lemma, lsjIndex, seenLSJEntries, !*isLatin)
 		}
 	}
+	return nil
 }
@@ -11,6 +11,18 @@ import (
 	"tlgread/pkg/tlgcore"
 )
 
+// betaRoundTrip returns the lines that do not survive EncodeBetaCode and
+// ToGreek unchanged.
+func betaRoundTrip(lines []string) []string {
+	var failed []string
+	for _, l := range lines {
+		if tlgcore.ToGreek(tlgcore.EncodeBetaCode(l)) != l {
+			failed = append(failed, l)
+		}
+	}
+	return failed
+}
+
 func main() {
 	dirPath := flag.String("d", ".", "Directory containing TLG/PHI files")
 
@@ -132,9 +144,9 @@ func main() {
 			// Setup Parser
 			p := tlgcore.NewParser(f)
 			p.IDTData = meta
-			if strings.HasPrefix(strings.ToLower(base), "lat") || strings.HasPrefix(strings.ToLower(base), "phi") {
-				p.IsLatinFile = true
-			}
+			p.IsLatinFile = tlgcore.IsLatinFileName(base)
+			p.IsCopticFile = tlgcore.IsCopticFileName(base)
+			p.IsDocumentFile = tlgcore.IsDocumentFileName(base)
 
 			// Extract first work ID
 			var firstWorkID string
@@ -158,6 +170,17 @@ func main() {
 				continue
 			}
 
+			// TEST D: Beta Code round trip of the Greek text
+			if p.Script() == tlgcore.ScriptGreek {
+				lines := strings.Split(text, "\n")
+				if failed := betaRoundTrip(lines); len(failed) > 0 {
+					fmt.Printf("[FAIL] Beta Code round trip: %d of %d lines differ, e.g. %q -> %q\n",
+						len(failed), len(lines), failed[0], tlgcore.EncodeBetaCode(failed[0]))
+					failCount++
+					continue
+				}
+			}
+
 			// Success
 			fmt.Printf("[PASS] Works: %d | Author: %.15s... | Text extracted: %d bytes\n", len(meta), author, len(text))
 			passCount++
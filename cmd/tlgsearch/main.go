@@ -0,0 +1,445 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"tlgread/pkg/tlgcore"
+)
+
+type Hit struct {
+	AuthorID string
+	Author   string
+	Title    string
+	Tokens   []tlgcore.Token
+	Context  tlgcore.KWIC // set only with -kwic
+}
+
+func (h Hit) Citation() string {
+	return h.Tokens[0].Line.FormattedCitation
+}
+
+func (h Hit) Concordance() tlgcore.Concordance {
+	return tlgcore.Concordance{
+		AuthorID: h.AuthorID,
+		Author:   h.Author,
+		WorkID:   h.Tokens[0].Line.WorkID,
+		Title:    h.Title,
+		Citation: h.Citation(),
+		KWIC:     h.Context,
+	}
+}
+
+// Matcher decides whether a token can stand at one position of a query.
+type Matcher func(tlgcore.Token) bool
+
+func keyMatchers(keys []string) []Matcher {
+	var ms []Matcher
+	for _, k := range keys {
+		ms = append(ms, func(t tlgcore.Token) bool { return t.Key == k })
+	}
+	return ms
+}
+
+// patternMatchers compiles one word pattern per whitespace-separated part
+// of the query.
+func patternMatchers(query string, isRegex bool, pol tlgcore.FoldPolicy) ([]Matcher, error) {
+	var ms []Matcher
+	for _, f := range strings.Fields(query) {
+		wp, err := tlgcore.CompileWordPattern(f, isRegex, pol)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, func(t tlgcore.Token) bool { return wp.Match(t.Text) })
+	}
+	return ms, nil
+}
+
+// searchText scans one text file for consecutive tokens accepted by the
+// matchers. With a width above 0 each hit comes with its KWIC context,
+// which delays it until enough text follows.
+func searchText(corpus *tlgcore.Corpus, textID string, match []Matcher, width int, emit func([]tlgcore.Token, tlgcore.KWIC)) error {
+	p, err := corpus.Open(textID)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	var kb *tlgcore.KWICBuilder
+	if width > 0 {
+		kb = tlgcore.NewKWICBuilder(width)
+		defer func() {
+			kb.Flush()
+			for _, k := range kb.Ready() {
+				emit(k.Tokens, k)
+			}
+		}()
+	}
+
+	var window []tlgcore.Token
+	for tok, err := range p.Tokens("") {
+		if err != nil {
+			return err
+		}
+		if kb != nil {
+			kb.Add(tok)
+			for _, k := range kb.Ready() {
+				emit(k.Tokens, k)
+			}
+		}
+		if len(window) > 0 && window[0].Line.WorkID != tok.Line.WorkID {
+			window = window[:0]
+		}
+		window = append(window, tok)
+		if len(window) > len(match) {
+			window = window[1:]
+		}
+		if len(window) < len(match) {
+			continue
+		}
+
+		ok := true
+		for i, m := range match {
+			if !m(window[i]) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		toks := append([]tlgcore.Token(nil), window...)
+		if kb != nil {
+			kb.Hit(toks)
+		} else {
+			emit(toks, tlgcore.KWIC{})
+		}
+	}
+	return nil
+}
+
+type searcher struct {
+	corpus *tlgcore.Corpus
+}
+
+func newSearcher(corpus *tlgcore.Corpus) *searcher {
+	return &searcher{corpus: corpus}
+}
+
+func (s *searcher) hit(textID string, toks []tlgcore.Token, kwic tlgcore.KWIC) Hit {
+	authorID, author := s.corpus.Author(textID)
+	return Hit{
+		AuthorID: authorID,
+		Author:   author,
+		Title:    s.corpus.Title(textID, toks[0].Line.WorkID),
+		Tokens:   toks,
+		Context:  kwic,
+	}
+}
+
+// lemmaForms expands a lemma into the keys of its inflected forms, each
+// with the spellings it stands for.
+func lemmaForms(lemmataPath, lemma string, isLatin bool) ([]string, map[string][]string, error) {
+	info, err := tlgcore.FindForms(lemmataPath, tlgcore.LemmaQuery(lemma))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keys []string
+	labels := make(map[string][]string)
+	for _, w := range info.Words() {
+		k := tlgcore.WordKey(w)
+		if k == "" {
+			continue
+		}
+		if _, ok := labels[k]; !ok {
+			keys = append(keys, k)
+		}
+		if !isLatin {
+			w = tlgcore.ToGreek(w)
+		}
+		labels[k] = append(labels[k], w)
+	}
+	return keys, labels, nil
+}
+
+// printByForm prints hits grouped by form and, within each form, by author.
+// A width above 0 prints KWIC rows, sorted by sortBy, instead of whole lines.
+func printByForm(hits []Hit, keys []string, labels map[string][]string, width int, sortBy string) {
+	byForm := make(map[string][]Hit)
+	for _, h := range hits {
+		byForm[h.Tokens[0].Key] = append(byForm[h.Tokens[0].Key], h)
+	}
+
+	for _, k := range keys {
+		fh := byForm[k]
+		if len(fh) == 0 {
+			continue
+		}
+		fmt.Fprintf(stdout, "=== %s (%d)\n", strings.Join(labels[k], ", "), len(fh))
+
+		var authors []string
+		byAuthor := make(map[string][]Hit)
+		for _, h := range fh {
+			if _, ok := byAuthor[h.AuthorID]; !ok {
+				authors = append(authors, h.AuthorID)
+			}
+			byAuthor[h.AuthorID] = append(byAuthor[h.AuthorID], h)
+		}
+		for _, a := range authors {
+			ah := byAuthor[a]
+			fmt.Fprintf(stdout, "  %s (%s): %d\n", ah[0].Author, a, len(ah))
+			if width > 0 {
+				var rows []tlgcore.Concordance
+				for _, h := range ah {
+					rows = append(rows, h.Concordance())
+				}
+				if sortBy != "" {
+					tlgcore.SortConcordance(rows, sortBy)
+				}
+				tlgcore.WriteConcordance(stdout, rows, "text", width)
+				continue
+			}
+			for _, h := range ah {
+				fmt.Fprintf(stdout, "    %s | %-10s %s\n", h.Title, h.Citation(), h.Tokens[0].Line.Text)
+			}
+		}
+	}
+}
+
+func printHit(h Hit) {
+	fmt.Fprintf(stdout, "%s (%s) | %s | %-10s %s\n", h.Author, h.AuthorID, h.Title, h.Citation(), h.Tokens[0].Line.Text)
+}
+
+// scanCorpus searches every text file in turn.
+func (s *searcher) scanCorpus(texts []string, match []Matcher, width int, emit func(Hit)) {
+	for _, textID := range texts {
+		err := searchText(s.corpus, textID, match, width, func(toks []tlgcore.Token, kwic tlgcore.KWIC) {
+			emit(s.hit(textID, toks, kwic))
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", textID, err)
+		}
+	}
+}
+
+// searchIndex answers the query from a corpus index, reading only the
+// blocks that hold a hit to recover the line text, or with a width above
+// 0 the KWIC context around it.
+func (s *searcher) searchIndex(ix *tlgcore.Index, keys []string, width int, emit func(Hit)) error {
+	postings, err := ix.Phrase(keys)
+	if err != nil {
+		return err
+	}
+
+	parsers := make(map[string]*tlgcore.Parser)
+	defer func() {
+		for _, p := range parsers {
+			p.Close()
+		}
+	}()
+
+	// nth counts earlier hits on the same line, which KWICAt needs to
+	// tell them apart.
+	nth, lastLine := 0, tlgcore.Posting{}
+	for _, post := range postings {
+		if post.TextID == lastLine.TextID && post.WorkID == lastLine.WorkID && post.Line == lastLine.Line {
+			nth++
+		} else {
+			nth, lastLine = 0, post
+		}
+
+		p, ok := parsers[post.TextID]
+		if !ok {
+			p, err = s.corpus.Open(post.TextID)
+			if err != nil {
+				return err
+			}
+			parsers[post.TextID] = p
+		}
+
+		if width > 0 {
+			kwic, err := p.KWICAt(post.WorkID, post.Block, post.Citation, keys, nth, width)
+			if err == nil {
+				emit(s.hit(post.TextID, kwic.Tokens, kwic))
+				continue
+			}
+		}
+
+		line, err := p.LineAt(post.WorkID, post.Block, post.Citation)
+		if err != nil {
+			line = tlgcore.Line{WorkID: post.WorkID, FormattedCitation: post.Citation}
+		}
+		tok := tlgcore.Token{Key: keys[0], Pos: post.Pos, Line: &line}
+		emit(s.hit(post.TextID, []tlgcore.Token{tok}, tlgcore.KWIC{}))
+	}
+	return nil
+}
+
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
+
+func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	dirPath := flag.String("d", ".", "corpus root containing tlg*.txt / lat*.txt")
+	query := flag.String("w", "", "word or phrase in Greek / Beta Code")
+	glob := flag.String("glob", "", "wildcard pattern, e.g. 'φιλο*' (* any letters, ? one letter)")
+	rePat := flag.String("re", "", "regular expression over whole words, e.g. 'λ[ιυ]σ.*'")
+	lemma := flag.String("lemma", "", "search every inflected form of a lemma")
+	lemmataPath := flag.String("lemmata", "", "greek-lemmata.txt (latin-lemmata.txt with -lat)")
+	isLatin := flag.Bool("lat", false, "search Latin (PHI) texts")
+	kind := flag.String("kind", "", tlgcore.TextKindUsage)
+	indexPath := flag.String("index", "", "corpus index built with indexer -corpus (-w only)")
+	kwic := flag.Bool("kwic", false, "print hits as keyword in context")
+	width := flag.Int("width", 40, "with -kwic, characters of context on each side")
+	sortBy := flag.String("sort", "", "with -kwic, sort by left, right, author or citation")
+	format := flag.String("format", "text", "output format: text, tsv or csv (tsv and csv imply -kwic)")
+
+	var pol tlgcore.FoldPolicy
+	flag.BoolVar(&pol.Accents, "accents", false, "with -glob/-re, distinguish accents")
+	flag.BoolVar(&pol.Breathings, "breathings", false, "with -glob/-re, distinguish breathings")
+	flag.BoolVar(&pol.Case, "case", false, "with -glob/-re, distinguish upper and lower case")
+	flag.BoolVar(&pol.Sigma, "sigma", false, "with -glob/-re, distinguish medial, final and lunate sigma")
+	flag.BoolVar(&pol.Subscript, "subscript", false, "with -glob/-re, distinguish iota subscript")
+	flag.BoolVar(&pol.Diaeresis, "diaeresis", false, "with -glob/-re, distinguish diaeresis")
+	flag.BoolVar(&pol.Adscript, "adscript", false, "with -glob/-re, treat iota subscript as adscript (ᾳ = αι)")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
+	flag.Parse()
+
+	var match []Matcher
+	var keys []string
+	var labels map[string][]string
+	var err error
+	switch {
+	case *lemma != "":
+		if *lemmataPath == "" {
+			*lemmataPath = "greek-lemmata.txt"
+			if *isLatin {
+				*lemmataPath = "latin-lemmata.txt"
+			}
+		}
+		keys, labels, err = lemmaForms(*lemmataPath, *lemma, *isLatin)
+		if err == nil {
+			forms := make(map[string]bool)
+			for _, k := range keys {
+				forms[k] = true
+			}
+			match = []Matcher{func(t tlgcore.Token) bool { return forms[t.Key] }}
+		}
+	case *glob != "":
+		match, err = patternMatchers(*glob, false, pol)
+	case *rePat != "":
+		match, err = patternMatchers(*rePat, true, pol)
+	default:
+		keys = tlgcore.QueryKeys(*query)
+		match = keyMatchers(keys)
+	}
+	if err != nil {
+		return err
+	}
+	if len(match) == 0 {
+		return errors.New("Usage: tlgsearch -d corpus (-w word | -lemma lemma | -glob pattern | -re regexp) [-lat] [-index file]")
+	}
+
+	switch *format {
+	case "text":
+	case "tsv", "csv":
+		*kwic = true
+	default:
+		return fmt.Errorf("unknown format %q (want text, tsv or csv)", *format)
+	}
+	if *sortBy != "" {
+		*kwic = true
+		if err := tlgcore.SortConcordance(nil, *sortBy); err != nil {
+			return err
+		}
+	}
+	if !*kwic {
+		*width = 0
+	} else if *width <= 0 {
+		return errors.New("-width must be positive")
+	}
+
+	corpus := tlgcore.OpenCorpus(*dirPath)
+	s := newSearcher(corpus)
+	count := 0
+	var hits []Hit
+	emit := func(h Hit) {
+		if labels != nil || *kwic {
+			hits = append(hits, h)
+		} else {
+			printHit(h)
+		}
+		count++
+	}
+
+	if *indexPath != "" {
+		if keys == nil {
+			return errors.New("-index supports only -w and -lemma queries")
+		}
+		ix, err := tlgcore.OpenIndex(*indexPath)
+		if err != nil {
+			return err
+		}
+		defer ix.Close()
+
+		// A lemma query looks up each form on its own.
+		queries := [][]string{keys}
+		if labels != nil {
+			queries = queries[:0]
+			for _, k := range keys {
+				queries = append(queries, []string{k})
+			}
+		}
+		for _, q := range queries {
+			if err := s.searchIndex(ix, q, *width, emit); err != nil {
+				return err
+			}
+		}
+	} else {
+		kinds, err := tlgcore.ParseTextKinds(*kind, *isLatin)
+		if err != nil {
+			return err
+		}
+		texts, err := corpus.Texts(kinds...)
+		if err != nil {
+			return err
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("no text files found under %s", *dirPath)
+		}
+		s.scanCorpus(texts, match, *width, emit)
+	}
+
+	if *kwic && labels == nil || *format != "text" {
+		var rows []tlgcore.Concordance
+		for _, h := range hits {
+			rows = append(rows, h.Concordance())
+		}
+		if *sortBy != "" {
+			if err := tlgcore.SortConcordance(rows, *sortBy); err != nil {
+				return err
+			}
+		}
+		if err := tlgcore.WriteConcordance(stdout, rows, *format, *width); err != nil {
+			return err
+		}
+		if *format != "text" {
+			return nil
+		}
+	} else if labels != nil {
+		printByForm(hits, keys, labels, *width, *sortBy)
+	}
+	fmt.Fprintf(stdout, "%d hits\n", count)
+	return nil
+}
@@ -1,28 +1,186 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"tlgread/pkg/scansion"
 	"tlgread/pkg/tlgcore"
 )
 
+// listJSON is the -list -json output.
+type listJSON struct {
+	Author tlgcore.AuthorRecord    `json:"author"`
+	Works  []*tlgcore.WorkMetadata `json:"works"`
+}
+
+// workJSON is the -w -json output.
+type workJSON struct {
+	Author       tlgcore.AuthorRecord  `json:"author"`
+	Work         *tlgcore.WorkMetadata `json:"work"`
+	URN          string                `json:"urn"`
+	Bibliography string                `json:"bibliography"`
+	Lines        []tlgcore.CitedLine   `json:"lines"`
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// workMeta returns the IDT entry of a work, or one with only the ID if
+// the IDT lacks it.
+func workMeta(idtData map[string]*tlgcore.WorkMetadata, id string) *tlgcore.WorkMetadata {
+	meta := idtData[id]
+	if meta == nil {
+		meta = &tlgcore.WorkMetadata{ID: id}
+	}
+	if meta.Citations == nil {
+		m := *meta
+		m.Citations = []tlgcore.CitationDef{}
+		meta = &m
+	}
+	return meta
+}
+
+// workLines returns the lines of a work, or of a passage of it when from
+// or to is given.
+func workLines(p *tlgcore.Parser, workID, from, to string) ([]tlgcore.Line, error) {
+	if from != "" || to != "" {
+		return p.PassageLines(workID, from, to)
+	}
+	var lines []tlgcore.Line
+	for l, err := range p.Lines(workID) {
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("work ID %s not found", workID)
+	}
+	return lines, nil
+}
+
+// writeHTML writes lines as an HTML page, citations in the first column
+// and the text with its editorial markup (see BetaTree.HTML) in the second.
+func writeHTML(w io.Writer, author, title string, lines []tlgcore.Line, script tlgcore.Script) {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s, %s</title>\n</head>\n<body>\n<table>\n",
+		script.Lang(), html.EscapeString(author), html.EscapeString(title))
+	for _, l := range lines {
+		fmt.Fprintf(bw, "<tr><td class=\"cit\">%s</td><td>%s</td></tr>\n",
+			html.EscapeString(l.FormattedCitation), strings.TrimSpace(tlgcore.ParseBeta(l.RawBetaCode, script).HTML()))
+	}
+	fmt.Fprint(bw, "</table>\n</body>\n</html>\n")
+	bw.Flush()
+}
+
+// reportUnknown prints each Beta Code command of the works that is not in
+// the tables, with its count and where it first occurs.
+func reportUnknown(w io.Writer, p *tlgcore.Parser, workIDs []string) error {
+	var codes []string
+	count := make(map[string]int)
+	first := make(map[string]string)
+	for _, id := range workIDs {
+		for l, err := range p.Lines(id) {
+			if err != nil {
+				return err
+			}
+			for _, c := range tlgcore.UnknownBetaCodes(l.RawBetaCode) {
+				if count[c] == 0 {
+					codes = append(codes, c)
+					first[c] = id + " " + l.FormattedCitation
+				}
+				count[c]++
+			}
+		}
+	}
+	for _, c := range codes {
+		fmt.Fprintf(w, "%-8s %6d  %s\n", c, count[c], first[c])
+	}
+	return nil
+}
+
+// stdout puts the output in the Unicode form chosen with -norm.
+var stdout = tlgcore.NewFormWriter(os.Stdout, tlgcore.NFC)
+
 func main() {
+	err := run()
+	stdout.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
 	fPath := flag.String("f", "", "TLG .txt")
 	wID := flag.String("w", "", "Work ID")
 	list := flag.Bool("list", false, "List")
+	unknown := flag.Bool("unknown", false, "list the Beta Code commands of -w, or of every work, that tlgviewer cannot decode")
+	from := flag.String("from", "", "first citation of passage (e.g. 2.34)")
+	to := flag.String("to", "", "last citation of passage (e.g. 2.46)")
+	format := flag.String("format", "text", "output format of -w: text, ansi, leiden, html or tei")
+	urn := flag.String("urn", "", "CTS URN of a work or passage, e.g. urn:cts:greekLit:tlg0012.tlg001:1.1-1.10")
+	dirPath := flag.String("d", ".", "corpus root for -urn")
+	asJSON := flag.Bool("json", false, "print -list or -w as JSON")
+	scan := flag.Bool("scan", false, "print the scansion of -w under each line")
+	meterName := flag.String("meter", "", "meter of -scan: hexameter, pentameter or trimeter (default: whichever fits)")
+	flag.Var(&stdout.Form, "norm", tlgcore.OutputFormUsage)
 	flag.Parse()
 
+	if *urn != "" {
+		u, err := tlgcore.ParseURN(*urn)
+		if err != nil {
+			return err
+		}
+		textID, err := tlgcore.OpenCorpus(*dirPath).FindText(u.TextID())
+		if err != nil {
+			return err
+		}
+		*fPath = filepath.Join(*dirPath, filepath.FromSlash(textID)+".txt")
+		*wID = u.WorkID()
+		*from, *to = u.From, u.To
+	}
+
+	switch *format {
+	case "text", "ansi", "leiden", "html", "tei":
+	default:
+		return fmt.Errorf("unknown format %q (want text, ansi, leiden, html or tei)", *format)
+	}
+	if *asJSON && *format != "text" {
+		return fmt.Errorf("-json and -format %s are exclusive", *format)
+	}
+	if *scan && (*asJSON || *format == "html" || *format == "tei") {
+		return errors.New("-scan works with -format text, ansi or leiden")
+	}
+	scanLine := scansion.Scan
+	if *meterName != "" {
+		m, err := scansion.ParseMeter(*meterName)
+		if err != nil {
+			return err
+		}
+		scanLine = func(line string) (scansion.Scansion, error) {
+			return scansion.ScanMeter(line, m)
+		}
+	}
+
 	if *fPath == "" {
-		log.Fatal("Usage: ./tlgviewer -f tlg[0000-9999].txt [-list] or [-w 1]")
+		return errors.New("Usage: ./tlgviewer -f tlg[0000-9999].txt [-list] or [-unknown] or [-w 1 [-from 1.1] [-to 1.10] [-scan [-meter hexameter]] | -w 1 -format ansi|leiden|html|tei] [-json]\n       ./tlgviewer -d corpus -urn urn:cts:greekLit:tlg0012.tlg001:1.1-1.10")
 	}
 
 	f, err := os.Open(*fPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer f.Close()
 
@@ -36,7 +194,7 @@ func main() {
 	idtData, err := tlgcore.ReadIDT(idtPath)
 
 	if err != nil {
-		fmt.Printf("Warning: Failed to read IDT file %s: %v\n", idtPath, err)
+		fmt.Fprintf(os.Stderr, "Warning: Failed to read IDT file %s: %v\n", idtPath, err)
 		idtData = make(map[string]*tlgcore.WorkMetadata)
 	}
 
@@ -53,7 +211,7 @@ func main() {
 			}
 		}
 	} else {
-		fmt.Printf("Warning: Could not read author table: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: Could not read author table: %v\n", err)
 	}
 
 	numericID := tlgID
@@ -71,7 +229,7 @@ func main() {
 	/*	if pathC2 != "" {
 			metaFields, err = tlgcore.GetMetadataFromCanonDB(pathC2, numericID, *wID)
 			if err != nil {
-				fmt.Printf("Warning: Failed to read doccan2 file %s: %v\n", pathC2, err)
+				fmt.Fprintf(stdout, "Warning: Failed to read doccan2 file %s: %v\n", pathC2, err)
 			}
 		}
 	*/
@@ -79,59 +237,144 @@ func main() {
 	p := tlgcore.NewParser(f)
 	p.IDTData = idtData
 
-	latinBase := []string{"LAT", "CIV", "PHI"}
+	p.IsLatinFile = tlgcore.IsLatinFileName(base)
+	p.IsCopticFile = tlgcore.IsCopticFileName(base)
+	p.IsDocumentFile = tlgcore.IsDocumentFileName(base)
 
-	for _, pref := range latinBase {
-		if strings.HasPrefix(strings.ToUpper(base), pref) {
-			p.IsLatinFile = true
-			break
+	if *list && *asJSON {
+		ids, err := p.WorkIDs(idtData)
+		if err != nil {
+			return err
 		}
-	}
+		out := listJSON{
+			Author: tlgcore.AuthorRecord{ID: strings.ToUpper(tlgID), Name: author},
+			Works:  []*tlgcore.WorkMetadata{},
+		}
+		for _, id := range ids {
+			out.Works = append(out.Works, workMeta(idtData, id))
+		}
+		return printJSON(out)
 
-	if *list {
-		fmt.Printf("File: %s (%s)\n", base, author)
-		fmt.Println("----------------------------------------")
+	} else if *unknown {
+		ids := []string{tlgcore.NormalizeID(*wID)}
+		if *wID == "" {
+			if ids, err = p.WorkIDs(idtData); err != nil {
+				return err
+			}
+		}
+		if err := reportUnknown(stdout, p, ids); err != nil {
+			return err
+		}
+
+	} else if *list {
+		fmt.Fprintf(stdout, "File: %s (%s)\n", base, author)
+		fmt.Fprintln(stdout, "----------------------------------------")
 
 		works, err := p.ExtractList(idtData)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		for _, w := range works {
-			fmt.Println(w)
+			fmt.Fprintln(stdout, w)
 		}
 
+	} else if *asJSON {
+		cleanWID := tlgcore.NormalizeID(*wID)
+		out := workJSON{
+			Author:       tlgcore.AuthorRecord{ID: strings.ToUpper(tlgID), Name: author},
+			Work:         workMeta(idtData, cleanWID),
+			URN:          tlgcore.WorkURN(tlgID, cleanWID).String(),
+			Bibliography: biblioText,
+			Lines:        []tlgcore.CitedLine{},
+		}
+		if *from != "" || *to != "" {
+			lines, err := p.PassageLines(cleanWID, *from, *to)
+			if err != nil {
+				return err
+			}
+			for _, l := range lines {
+				out.Lines = append(out.Lines, l.Cited(idtData[cleanWID]))
+			}
+		} else {
+			for l, err := range p.Lines(cleanWID) {
+				if err != nil {
+					return err
+				}
+				out.Lines = append(out.Lines, l.Cited(idtData[cleanWID]))
+			}
+			if len(out.Lines) == 0 {
+				return fmt.Errorf("work ID %s not found", cleanWID)
+			}
+		}
+		return printJSON(out)
+
+	} else if *format == "html" {
+		cleanWID := tlgcore.NormalizeID(*wID)
+		lines, err := workLines(p, cleanWID, *from, *to)
+		if err != nil {
+			return err
+		}
+		title := ""
+		if meta := idtData[cleanWID]; meta != nil {
+			title = meta.Title
+		}
+		writeHTML(stdout, author, title, lines, p.Script())
+
+	} else if *format == "tei" {
+		cleanWID := tlgcore.NormalizeID(*wID)
+		h := tlgcore.TEIHeader{
+			AuthorID:     strings.ToUpper(tlgID),
+			Author:       author,
+			WorkID:       cleanWID,
+			Bibliography: biblioText,
+		}
+		if meta := idtData[cleanWID]; meta != nil {
+			h.Title = meta.Title
+		}
+		var err error
+		if *from != "" || *to != "" {
+			var lines []tlgcore.Line
+			if lines, err = p.PassageLines(cleanWID, *from, *to); err == nil {
+				err = p.WriteTEILines(stdout, cleanWID, h, lines)
+			}
+		} else {
+			err = p.WriteTEI(stdout, cleanWID, h)
+		}
+		if err != nil {
+			return err
+		}
 	} else {
 		cleanWID := tlgcore.NormalizeID(*wID)
 
-		fmt.Println("========================================")
+		fmt.Fprintln(stdout, "========================================")
 
 		if biblioText != "" {
-			fmt.Println(">>> Bibliography")
-			fmt.Println(biblioText)
-			fmt.Println("")
+			fmt.Fprintln(stdout, ">>> Bibliography")
+			fmt.Fprintln(stdout, biblioText)
+			fmt.Fprintln(stdout, "")
 		}
 
 		/*
 			if len(metaFields) > 0 {
-				fmt.Println(">>> Database Metadata")
+				fmt.Fprintln(stdout, ">>> Database Metadata")
 				for _, field := range metaFields {
 					if field.Tag == "---" {
-						fmt.Printf("\n--- %s ---\n", field.Value)
+						fmt.Fprintf(stdout, "\n--- %s ---\n", field.Value)
 					} else {
-						fmt.Printf("%-15s [%s]: %s\n", field.Label, field.Tag, field.Value)
+						fmt.Fprintf(stdout, "%-15s [%s]: %s\n", field.Label, field.Tag, field.Value)
 					}
 				}
 			}
 
 			if biblioText == "" && len(metaFields) == 0 {
-				fmt.Println("(No Bibliography or Metadata found in Canon files)")
+				fmt.Fprintln(stdout, "(No Bibliography or Metadata found in Canon files)")
 			}
 		*/
 		if biblioText == "" {
-			fmt.Println("(No Bibliography data found)")
+			fmt.Fprintln(stdout, "(No Bibliography data found)")
 		}
 
-		fmt.Println("========================================")
+		fmt.Fprintln(stdout, "========================================")
 
 		title := "(Unknown Title)"
 		meta := idtData[cleanWID]
@@ -139,21 +382,52 @@ func main() {
 			title = meta.Title
 		}
 
-		fmt.Printf("Author: %s\nWork:   %s (ID: %s)\n", author, title, cleanWID)
+		fmt.Fprintf(stdout, "Author: %s\nWork:   %s (ID: %s)\n", author, title, cleanWID)
+		fmt.Fprintf(stdout, "URN:    %s\n", tlgcore.WorkURN(tlgID, cleanWID))
 
 		if meta != nil && len(meta.Citations) > 0 {
 			for _, c := range meta.Citations {
-				fmt.Printf("%s (%s) ", c.Label, c.LevelChar)
+				fmt.Fprintf(stdout, "%s (%s) ", c.Label, c.LevelChar)
 			}
-			fmt.Printf("\n")
+			fmt.Fprintf(stdout, "\n")
 		}
-		fmt.Println("----------------------------------------")
+		fmt.Fprintln(stdout, "----------------------------------------")
 
-		text, err := p.ExtractWork(cleanWID)
+		var text string
+		if *format == "ansi" || *format == "leiden" || *scan {
+			var lines []tlgcore.Line
+			lines, err = workLines(p, cleanWID, *from, *to)
+			var sb strings.Builder
+			for _, l := range lines {
+				t := tlgcore.ParseBeta(l.RawBetaCode, p.Script())
+				switch *format {
+				case "leiden":
+					fmt.Fprintf(&sb, "%-10s %s\n", l.FormattedCitation, t.Leiden())
+				case "ansi":
+					fmt.Fprintf(&sb, "%-10s %s\n", l.FormattedCitation, t.ANSI())
+				default:
+					fmt.Fprintf(&sb, "%-10s %s\n", l.FormattedCitation, l.Text)
+				}
+				if *scan {
+					sc, err := scanLine(l.Text)
+					if err != nil {
+						fmt.Fprintf(&sb, "%-10s (%v)\n", "", err)
+					} else {
+						fmt.Fprintf(&sb, "%-10s %s\n", "", sc)
+					}
+				}
+			}
+			text = sb.String()
+		} else if *from != "" || *to != "" {
+			text, err = p.ExtractPassage(cleanWID, *from, *to)
+		} else {
+			text, err = p.ExtractWork(cleanWID)
+		}
 		if err != nil {
-			fmt.Println("Error:", err)
+			fmt.Fprintln(stdout, "Error:", err)
 		} else {
-			fmt.Print(text)
+			fmt.Fprint(stdout, text)
 		}
 	}
+	return nil
 }
@@ -0,0 +1,432 @@
+// Package scansion scans Greek verse lines: it finds the quantity of each
+// syllable and fits the line to dactylic hexameter, elegiac pentameter or
+// iambic trimeter.
+package scansion
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"tlgread/pkg/tlgcore"
+)
+
+// Quantity is the length of a syllable.
+type Quantity int
+
+const (
+	Common Quantity = iota // long or short, as the verse needs
+	Long
+	Short
+)
+
+func (q Quantity) String() string {
+	switch q {
+	case Long:
+		return "–"
+	case Short:
+		return "⏑"
+	}
+	return "×"
+}
+
+// Syllable is a syllable of a line, named by its vowel or diphthong.
+type Syllable struct {
+	Vowel    string
+	Quantity Quantity
+}
+
+// Meter is a verse form a line can be fitted to.
+type Meter int
+
+const (
+	Hexameter Meter = iota
+	Pentameter
+	Trimeter
+)
+
+var meterNames = []string{"hexameter", "pentameter", "trimeter"}
+
+func (m Meter) String() string {
+	return meterNames[m]
+}
+
+// ParseMeter returns the meter named s, as Meter.String writes it.
+func ParseMeter(s string) (Meter, error) {
+	for i, name := range meterNames {
+		if s == name {
+			return Meter(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown meter %q (want %s)", s, strings.Join(meterNames, ", "))
+}
+
+// Scansion is a line fitted to a meter: the quantities of its syllables,
+// grouped by foot, or by metron in iambic trimeter. The last syllable and
+// a single syllable in an anceps position keep their own quantity, which
+// may be Common.
+type Scansion struct {
+	Meter Meter
+	Feet  [][]Quantity
+}
+
+// String writes the scansion as "– ⏑ ⏑ | – – | ...", with the pentameter
+// divided into its halves by "||".
+func (s Scansion) String() string {
+	var sb strings.Builder
+	for i, foot := range s.Feet {
+		if i > 0 {
+			if s.Meter == Pentameter && i == 3 {
+				sb.WriteString(" || ")
+			} else {
+				sb.WriteString(" | ")
+			}
+		}
+		for j, q := range foot {
+			if j > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(q.String())
+		}
+	}
+	return sb.String()
+}
+
+// Scan fits a decoded Greek line to hexameter, pentameter or trimeter,
+// trying them in that order.
+func Scan(line string) (Scansion, error) {
+	for _, m := range []Meter{Hexameter, Pentameter, Trimeter} {
+		if s, err := ScanMeter(line, m); err == nil {
+			return s, nil
+		}
+	}
+	return Scansion{}, fmt.Errorf("line does not scan as %s", strings.Join(meterNames, ", "))
+}
+
+// ScanMeter fits a decoded Greek line to m. Hexameter and pentameter allow
+// epic correption. Where the line does not scan as written, ε is taken
+// together with a following vowel (synizesis), as in Πηληϊάδεω.
+func ScanMeter(line string, m Meter) (Scansion, error) {
+	nuc := nuclei(letters(line))
+	epic := m != Trimeter
+
+	var merges [][]int
+	var all []int
+	for i := 0; i+1 < len(nuc); i++ {
+		if nuc[i].vowel == "ε" && nuc[i].cons == 0 && nuc[i+1].word == nuc[i].word {
+			merges = append(merges, []int{i})
+			all = append(all, i)
+		}
+	}
+	if len(all) > 1 {
+		merges = append(merges, all)
+	}
+
+	if feet, ok := fit(syllables(nuc, epic), meters[m]); ok {
+		return Scansion{Meter: m, Feet: feet}, nil
+	}
+	for _, mg := range merges {
+		if feet, ok := fit(syllables(synizesis(nuc, mg), epic), meters[m]); ok {
+			return Scansion{Meter: m, Feet: feet}, nil
+		}
+	}
+	return Scansion{}, fmt.Errorf("line does not scan as %s", m)
+}
+
+// Syllables returns the syllables of a decoded Greek line with the
+// quantities they have by nature and by position, before any meter is
+// applied.
+func Syllables(line string) []Syllable {
+	return syllables(nuclei(letters(line)), false)
+}
+
+type letter struct {
+	base  rune
+	marks []rune
+	word  int
+}
+
+const (
+	vowels     = "αεηιουω"
+	consonants = "βγδζθκλμνξπρστφχψϝ"
+)
+
+// letters reduces a line to its Greek letters, lower case and numbered by
+// word, each with its diacritics.
+func letters(line string) []letter {
+	var ls []letter
+	word, inWord := 0, false
+	for _, r := range line {
+		if unicode.Is(unicode.Mn, r) {
+			if inWord {
+				ls[len(ls)-1].marks = append(ls[len(ls)-1].marks, r)
+			}
+			continue
+		}
+		if r == '’' || r == '\'' || r == 'ʼ' {
+			continue // elision
+		}
+		base, marks := tlgcore.Decompose(r)
+		base = unicode.ToLower(base)
+		if base == 'ς' || base == 'ϲ' {
+			base = 'σ'
+		}
+		if !strings.ContainsRune(vowels+consonants, base) {
+			if inWord {
+				word++
+				inWord = false
+			}
+			continue
+		}
+		inWord = true
+		ls = append(ls, letter{base: base, marks: append([]rune(nil), marks...), word: word})
+	}
+	return ls
+}
+
+func hasMark(marks []rune, m rune) bool {
+	for _, r := range marks {
+		if r == m {
+			return true
+		}
+	}
+	return false
+}
+
+// nucleus is a vowel or diphthong with the consonants that follow it up to
+// the next one.
+type nucleus struct {
+	vowel   string
+	word    int
+	q       Quantity // by nature
+	accent  rune
+	cons    int // consonants after it; ζ, ξ and ψ count twice
+	cluster []letter
+}
+
+var diphthongs = map[string]bool{
+	"αι": true, "ει": true, "οι": true, "υι": true,
+	"αυ": true, "ευ": true, "ηυ": true, "ου": true, "ωυ": true,
+}
+
+func nuclei(ls []letter) []nucleus {
+	var nuc []nucleus
+	for i := 0; i < len(ls); i++ {
+		l := ls[i]
+		if !strings.ContainsRune(vowels, l.base) {
+			if n := len(nuc); n > 0 {
+				nuc[n-1].cons++
+				if strings.ContainsRune("ζξψ", l.base) {
+					nuc[n-1].cons++
+				}
+				nuc[n-1].cluster = append(nuc[n-1].cluster, l)
+			}
+			continue
+		}
+
+		n := nucleus{vowel: string(l.base), word: l.word}
+		marks := l.marks
+		if i+1 < len(ls) && isDiphthong(l, ls[i+1]) {
+			i++
+			n.vowel += string(ls[i].base)
+			marks = append(append([]rune(nil), marks...), ls[i].marks...)
+			n.q = Long
+		} else {
+			n.q = nature(l.base, marks)
+		}
+		for _, m := range marks {
+			if m == '́' || m == '̀' || m == '͂' {
+				n.accent = m
+			}
+		}
+		nuc = append(nuc, n)
+	}
+	accentRules(nuc)
+	return nuc
+}
+
+// isDiphthong reports whether a and b form a diphthong. The breathing and
+// accent of a diphthong stand on its second vowel; a diaeresis parts it.
+func isDiphthong(a, b letter) bool {
+	if a.word != b.word || hasMark(b.marks, '̈') || !diphthongs[string([]rune{a.base, b.base})] {
+		return false
+	}
+	for _, m := range a.marks {
+		if m != '̄' && m != '̆' {
+			return false
+		}
+	}
+	return true
+}
+
+// nature is the quantity of a single vowel: η and ω are long, ε and ο
+// short, and α, ι and υ long under a circumflex, an iota subscript or a
+// macron, short under a breve and otherwise unknown.
+func nature(v rune, marks []rune) Quantity {
+	switch {
+	case v == 'η' || v == 'ω':
+		return Long
+	case v == 'ε' || v == 'ο':
+		return Short
+	case hasMark(marks, '͂') || hasMark(marks, 'ͅ') || hasMark(marks, '̄'):
+		return Long
+	case hasMark(marks, '̆'):
+		return Short
+	}
+	return Common
+}
+
+// accentRules settles α, ι and υ from the accent of their word: a final
+// vowel is short after a circumflex on the penult or an acute on the
+// antepenult, and an accented penult is short when it has an acute over a
+// short final (final -αι and -οι count as short here).
+func accentRules(nuc []nucleus) {
+	for start := 0; start < len(nuc); {
+		end := start
+		for end < len(nuc) && nuc[end].word == nuc[start].word {
+			end++
+		}
+		w := nuc[start:end]
+		start = end
+
+		n := len(w)
+		last := &w[n-1]
+		single := len([]rune(last.vowel)) == 1
+		if n >= 2 && w[n-2].accent == '͂' && last.q == Common && single {
+			last.q = Short
+		}
+		if n >= 3 && w[n-3].accent == '́' && last.q == Common && single {
+			last.q = Short
+		}
+		shortFinal := last.q == Short || last.vowel == "αι" || last.vowel == "οι"
+		if n >= 2 && w[n-2].accent == '́' && w[n-2].q == Common && shortFinal && last.cons == 0 {
+			w[n-2].q = Short
+		}
+	}
+}
+
+// synizesis joins each nucleus at the indices in at with the next one into
+// one long syllable.
+func synizesis(nuc []nucleus, at []int) []nucleus {
+	out := append([]nucleus(nil), nuc...)
+	for k := len(at) - 1; k >= 0; k-- {
+		i := at[k]
+		joined := out[i+1]
+		joined.vowel = out[i].vowel + joined.vowel
+		joined.q = Long
+		out = append(out[:i], append([]nucleus{joined}, out[i+2:]...)...)
+	}
+	return out
+}
+
+// syllables adds position to the quantities by nature: a vowel before two
+// consonants is long, unless they are a stop and a liquid or nasal in one
+// word (muta cum liquida), which leave it either. With epic, a long vowel
+// or diphthong ending a word before a vowel may be shortened (correption).
+func syllables(nuc []nucleus, epic bool) []Syllable {
+	syls := make([]Syllable, len(nuc))
+	for i, n := range nuc {
+		q := n.q
+		if i+1 < len(nuc) {
+			switch {
+			case n.cons >= 2 && mutaCumLiquida(n.cluster):
+				if q == Short {
+					q = Common
+				}
+			case n.cons >= 2:
+				q = Long
+			case epic && n.cons == 0 && q == Long && nuc[i+1].word != n.word:
+				q = Common
+			}
+		}
+		syls[i] = Syllable{Vowel: n.vowel, Quantity: q}
+	}
+	return syls
+}
+
+func mutaCumLiquida(c []letter) bool {
+	return len(c) == 2 && c[0].word == c[1].word &&
+		strings.ContainsRune("πβφτδθκγχ", c[0].base) && strings.ContainsRune("λρμν", c[1].base)
+}
+
+// element is a place in a metrical pattern.
+type element int
+
+const (
+	long       element = iota // –
+	short                     // ⏑
+	biceps                    // ⏑ ⏑ or –
+	anceps                    // – or ⏑, or ⏑ ⏑ resolved
+	resolvable                // –, or ⏑ ⏑ resolved
+	final                     // last syllable, of any quantity
+)
+
+var realizations = map[element][][]Quantity{
+	long:       {{Long}},
+	short:      {{Short}},
+	biceps:     {{Short, Short}, {Long}},
+	anceps:     {{Long}, {Short}, {Short, Short}},
+	resolvable: {{Long}, {Short, Short}},
+}
+
+var (
+	dactyl = []element{long, biceps}
+	metron = []element{anceps, resolvable, short, resolvable}
+)
+
+var meters = map[Meter][][]element{
+	Hexameter:  {dactyl, dactyl, dactyl, dactyl, dactyl, {long, final}},
+	Pentameter: {dactyl, dactyl, {long}, {long, short, short}, {long, short, short}, {final}},
+	Trimeter:   {metron, metron, {anceps, resolvable, short, final}},
+}
+
+// fit matches syllables to a pattern, preferring dactyls to spondees and
+// unresolved to resolved elements.
+func fit(syls []Syllable, feet [][]element) ([][]Quantity, bool) {
+	out := make([][]Quantity, len(feet))
+	var match func(f, e, s int) bool
+	match = func(f, e, s int) bool {
+		if f == len(feet) {
+			return s == len(syls)
+		}
+		if e == len(feet[f]) {
+			return match(f+1, 0, s)
+		}
+		if s == len(syls) {
+			return false
+		}
+		n := len(out[f])
+		if feet[f][e] == final {
+			out[f] = append(out[f][:n], syls[s].Quantity)
+			if match(f, e+1, s+1) {
+				return true
+			}
+			out[f] = out[f][:n]
+			return false
+		}
+	alternatives:
+		for _, alt := range realizations[feet[f][e]] {
+			if s+len(alt) > len(syls) {
+				continue
+			}
+			for k, q := range alt {
+				if have := syls[s+k].Quantity; have != Common && have != q {
+					continue alternatives
+				}
+			}
+			out[f] = append(out[f][:n], alt...)
+			if feet[f][e] == anceps && len(alt) == 1 {
+				out[f][n] = syls[s].Quantity
+			}
+			if match(f, e+1, s+len(alt)) {
+				return true
+			}
+		}
+		out[f] = out[f][:n]
+		return false
+	}
+	if !match(0, 0, 0) {
+		return nil, false
+	}
+	return out, true
+}
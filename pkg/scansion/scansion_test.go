@@ -0,0 +1,79 @@
+package scansion
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		line  string
+		meter Meter
+		want  string
+	}{
+		// Iliad 1.1-2
+		{"μῆνιν ἄειδε θεὰ Πηληϊάδεω Ἀχιλῆος", Hexameter, "– ⏑ ⏑ | – ⏑ ⏑ | – – | – ⏑ ⏑ | – ⏑ ⏑ | – ⏑"},
+		{"οὐλομένην, ἣ μυρί’ Ἀχαιοῖς ἄλγε’ ἔθηκε,", Hexameter, "– ⏑ ⏑ | – – | – ⏑ ⏑ | – – | – ⏑ ⏑ | – ⏑"},
+		// Odyssey 1.1
+		{"ἄνδρα μοι ἔννεπε, μοῦσα, πολύτροπον, ὃς μάλα πολλὰ", Hexameter, "– ⏑ ⏑ | – ⏑ ⏑ | – ⏑ ⏑ | – ⏑ ⏑ | – ⏑ ⏑ | – ×"},
+		// Archilochus fr. 1
+		{"εἰμὶ δ’ ἐγὼ θεράπων μὲν Ἐνυαλίοιο ἄνακτος", Hexameter, "– ⏑ ⏑ | – ⏑ ⏑ | – ⏑ ⏑ | – ⏑ ⏑ | – ⏑ ⏑ | – ⏑"},
+		{"καὶ Μουσέων ἐρατὸν δῶρον ἐπιστάμενος", Pentameter, "– – | – ⏑ ⏑ | – || – ⏑ ⏑ | – ⏑ ⏑ | ⏑"},
+		// Sophocles, Antigone 1-2: τά and δί are anceps of uncertain length.
+		{"ὦ κοινὸν αὐτάδελφον Ἰσμήνης κάρα,", Trimeter, "– – ⏑ – | × – ⏑ – | – – ⏑ ×"},
+		{"ἆρ’ οἶσθ’ ὅ τι Ζεὺς τῶν ἀπ’ Οἰδίπου κακῶν", Trimeter, "– – ⏑ – | – – ⏑ – | × – ⏑ –"},
+		// Euripides, Medea 1
+		{"Εἴθ’ ὤφελ’ Ἀργοῦς μὴ διαπτάσθαι σκάφος", Trimeter, "– – ⏑ – | – – ⏑ – | – – ⏑ ⏑"},
+	}
+	for _, tt := range tests {
+		s, err := Scan(tt.line)
+		if err != nil {
+			t.Errorf("Scan(%q): %v", tt.line, err)
+			continue
+		}
+		if s.Meter != tt.meter || s.String() != tt.want {
+			t.Errorf("Scan(%q) = %v %q, want %v %q", tt.line, s.Meter, s, tt.meter, tt.want)
+		}
+	}
+}
+
+func TestScanMeter(t *testing.T) {
+	tests := []struct {
+		line  string
+		meter Meter
+		ok    bool
+	}{
+		{"μῆνιν ἄειδε θεὰ Πηληϊάδεω Ἀχιλῆος", Hexameter, true},
+		{"μῆνιν ἄειδε θεὰ Πηληϊάδεω Ἀχιλῆος", Trimeter, false},
+		{"ὦ κοινὸν αὐτάδελφον Ἰσμήνης κάρα,", Trimeter, true},
+		{"ὦ κοινὸν αὐτάδελφον Ἰσμήνης κάρα,", Hexameter, false},
+		{"ἄνδρα μοι ἔννεπε", Hexameter, false},
+		{"", Hexameter, false},
+	}
+	for _, tt := range tests {
+		s, err := ScanMeter(tt.line, tt.meter)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("ScanMeter(%q, %v) = %q, %v; want ok = %v", tt.line, tt.meter, s, err, tt.ok)
+		} else if ok && s.Meter != tt.meter {
+			t.Errorf("ScanMeter(%q, %v) gave a %v", tt.line, tt.meter, s.Meter)
+		}
+	}
+}
+
+func TestSyllables(t *testing.T) {
+	got := fmt.Sprint(Syllables("μῆνιν ἄειδε"))
+	if want := "[{η –} {ι ⏑} {α ×} {ει –} {ε ⏑}]"; got != want {
+		t.Errorf("Syllables = %s, want %s", got, want)
+	}
+}
+
+func TestParseMeter(t *testing.T) {
+	for _, m := range []Meter{Hexameter, Pentameter, Trimeter} {
+		if got, err := ParseMeter(m.String()); err != nil || got != m {
+			t.Errorf("ParseMeter(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseMeter("alcaic"); err == nil {
+		t.Error("ParseMeter accepted an unknown meter")
+	}
+}
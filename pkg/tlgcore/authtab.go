@@ -1,13 +1,14 @@
 package tlgcore
 
 import (
+	"io/fs"
 	"os"
 	"strings"
 )
 
 type AuthorRecord struct {
-	ID   string
-	Name string
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 func ReadAuthorTable(path string) ([]AuthorRecord, error) {
@@ -15,7 +16,18 @@ func ReadAuthorTable(path string) ([]AuthorRecord, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseAuthorTable(data), nil
+}
+
+func ReadAuthorTableFS(fsys fs.FS, name string) ([]AuthorRecord, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAuthorTable(data), nil
+}
 
+func ParseAuthorTable(data []byte) []AuthorRecord {
 	var records []AuthorRecord
 	i := 0
 	for i < len(data) {
@@ -28,7 +40,7 @@ func ReadAuthorTable(path string) ([]AuthorRecord, error) {
 		i++
 	}
 
-	return records, nil
+	return records
 }
 
 func decodeAuthorEntry(data []byte, start int) (AuthorRecord, int) {
@@ -99,7 +111,7 @@ func isNewRecordStart(buf []byte) bool {
 	}
 
 	prefix := string(buf[:3])
-	if prefix == "TLG" || prefix == "LAT" || prefix == "CIV" || prefix == "COP" || prefix == "L  " {
+	if prefix == "TLG" || prefix == "LAT" || prefix == "CIV" || prefix == "COP" || prefix == "DDP" || prefix == "INS" || prefix == "L  " {
 		if buf[3] >= '0' && buf[3] <= '9' {
 			return true
 		}
@@ -51,331 +51,156 @@ var bcmHandlers = map[rune]bcmHandler{
 
 // $
 func handleGreek(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
-	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-
-	switch command {
-	case "$1":
-		isLatin = false
-	case "$2":
-	case "$3":
-	case "$10":
-	default:
-	}
-
-	isLatin = false
-	return nextIdx, isLatin, inQuot
+	_, nextIdx := parseCommand(runes, start)
+	return nextIdx, false, inQuo
 }
 
 // &
 func handleLatin(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
-	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-
-	switch command {
-	case "&":
-	default:
-	}
-
-	isLatin = true
-	return nextIdx, isLatin, inQuot
+	_, nextIdx := parseCommand(runes, start)
+	return nextIdx, true, inQuo
 }
 
 // @
 func handlePageFormatting(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "@":
-		out.WriteString("  ")
-	case "@6":
-	case "@70":
-		out.WriteString(" << ")
-	case "@71":
-		out.WriteString(" >> ")
-	default:
-	}
-
-	return nextIdx, isLatin, inQuot
+	out.WriteString(betaPage[command])
+	return nextIdx, isLat, inQuo
 }
 
 // {
 func handleMarkupText(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "{":
-		out.WriteString(" ")
-	case "{70": // TLG Editorial Text
-		isLatin = true
-	default:
+	out.WriteString(betaMarkup[command[1:]].Open)
+	if command == "{70" { // TLG Editorial Text
+		isLat = true
 	}
-
-	return nextIdx, isLatin, inQuot
+	return nextIdx, isLat, inQuo
 }
 
 // }
 func handleEndMarkupText(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	default:
-	}
-
-	return nextIdx, isLatin, inQuot
+	out.WriteString(betaMarkup[command[1:]].Close)
+	return nextIdx, isLat, inQuo
 }
 
 // <
 func handleTextFormatting(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "<":
-	case "<20":
-		out.WriteString("<")
-	default:
-	}
-
-	return nextIdx, isLatin, inQuot
+	out.WriteString(betaFormats[command[1:]].Open)
+	return nextIdx, isLat, inQuo
 }
 
 // >
 func handleTextFormattingClose(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case ">":
-	case ">20":
-		out.WriteString(">")
-	default:
-	}
-
-	return nextIdx, isLatin, inQuot
+	out.WriteString(betaFormats[command[1:]].Close)
+	return nextIdx, isLat, inQuo
 }
 
 // "
 func handleQuotation(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "\"1":
-		out.WriteString("\"")
-	case "\"2":
-		out.WriteString("\"")
-	case "\"3":
-		out.WriteString("'")
-	case "\"4":
-		out.WriteString("'")
-	case "\"5":
-		out.WriteString("'")
-	case "\"6":
-		if !inQuo {
-			out.WriteString("«")
-			inQuot = true
-		} else {
-			out.WriteString("»")
-			inQuot = false
+	q, ok := betaQuotes[command]
+	switch {
+	case !ok:
+		inQuo = false
+	case q[0] == q[1]:
+		out.WriteString(q[0])
+		if command == "\"8" {
+			inQuo = false
 		}
-	case "\"7":
-		if !inQuo {
-			out.WriteString("‹")
-			inQuot = true
-		} else {
-			out.WriteString("›")
-			inQuot = false
-		}
-	case "\"8":
-		out.WriteString("\"")
-		inQuot = false
+	case !inQuo:
+		out.WriteString(q[0])
+		inQuo = true
 	default:
-		inQuot = false
+		out.WriteString(q[1])
+		inQuo = false
 	}
-
-	return nextIdx, isLatin, inQuot
+	return nextIdx, isLat, inQuo
 }
 
 // [
 func handleOpenBracket(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "[":
-		out.WriteString("[")
-	case "[1":
-		out.WriteString("(")
-	case "[2":
-		out.WriteString("<")
-	case "[3":
-		out.WriteString("{")
-	case "[4":
-		out.WriteString("⟦")
-	case "[5":
-		out.WriteString("⌊")
-	case "[6":
-		out.WriteString("⌈")
-	case "[7":
-		out.WriteString("⌈")
-	case "[8":
-		out.WriteString("⌊")
-	case "[9":
-		out.WriteString("˙")
-	default:
+	if g, ok := betaOpenBrackets[command]; ok {
+		out.WriteString(g)
+	} else {
 		out.WriteString("[")
 	}
-
-	return nextIdx, isLatin, inQuot
+	return nextIdx, isLat, inQuo
 }
 
 // ]
 func handleCloseBracket(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "]":
-		out.WriteString("]")
-	case "]1":
-		out.WriteString(")")
-	case "]2":
-		out.WriteString(">")
-	case "]3":
-		out.WriteString("}")
-	case "]4":
-		out.WriteString("⟧")
-	case "]5":
-		out.WriteString("⌋")
-	case "]6":
-		out.WriteString("⌉")
-	case "]7":
-		out.WriteString("⌋")
-	case "]8":
-		out.WriteString("⌉")
-	case "]9":
-		out.WriteString("˙")
-	default:
+	if g, ok := betaCloseBrackets[command]; ok {
+		out.WriteString(g)
+	} else {
 		out.WriteString("]")
 	}
-
-	return nextIdx, isLatin, inQuot
+	return nextIdx, isLat, inQuo
 }
 
 // %
 func handleAddPunct(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
-
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "%":
-		out.WriteString("†")
-	case "%1":
-		out.WriteString("?")
-	case "%2":
-		out.WriteString("*")
-	case "%3":
-		out.WriteString("/")
-	case "%4":
-		out.WriteString("!")
-	case "%5":
-		out.WriteString("|")
-	case "%6":
-		out.WriteString("=")
-	case "%7":
-		out.WriteString("+")
-	case "%8":
-		out.WriteString("%")
-	case "%9":
-		out.WriteString("&")
-	case "%10":
-		out.WriteString(":")
-	case "%11":
-		out.WriteString("•")
-	case "%12":
-		out.WriteString("*")
-	case "%13":
-		out.WriteString("‡")
-	case "%14":
-		out.WriteString("§")
-	case "%18":
-		out.WriteString("'")
-	case "%19":
-		out.WriteString("-")
-	case "%41":
-		out.WriteString("-")
-	case "%43":
-		out.WriteString("×")
-	case "%103":
-		out.WriteString("\\")
-	case "%107":
-		out.WriteString("~")
-	default:
-	}
-
-	return nextIdx, isLatin, inQuot
+	out.WriteString(betaPunct[command])
+	return nextIdx, isLat, inQuo
 }
 
 // #
 func handleAddChar(runes []rune, start int, out *bytes.Buffer, isLat bool, inQuo bool) (newIdx int, isLatin bool, inQuot bool) {
 	command, nextIdx := parseCommand(runes, start)
+	out.WriteString(betaChars[command])
+	return nextIdx, isLat, inQuo
+}
 
-	inQuot = inQuo
-	isLatin = isLat
-
-	switch command {
-	case "#12":
-		out.WriteString("—")
-	case "#13":
-		out.WriteString("※")
-	case "#15":
-		out.WriteString(">")
-	case "#17":
-		out.WriteString("/")
-	case "#18":
-		out.WriteString("<")
-	default:
+// medialSigma stands in for σ written as S1 until final sigmas are placed.
+const medialSigma = '\uE000'
+
+// Script is the alphabet Beta Code letters are decoded into.
+type Script int
+
+const (
+	ScriptGreek Script = iota
+	ScriptLatin
+	ScriptCoptic
+)
+
+// Lang is the language code of text in the script.
+func (s Script) Lang() string {
+	switch s {
+	case ScriptLatin:
+		return "la"
+	case ScriptCoptic:
+		return "cop"
 	}
+	return "grc"
+}
 
-	return nextIdx, isLatin, inQuot
+// fontScript is the script selected by a $ font command. $50 is Coptic;
+// in Coptic text every $ font stays Coptic.
+func fontScript(code string, base Script) Script {
+	if code == "$50" || base == ScriptCoptic {
+		return ScriptCoptic
+	}
+	return ScriptGreek
 }
 
 func ToGreek(s string) string {
-	return parseBetaCode(s, false)
+	return parseBetaCode(s, ScriptGreek)
 }
 
 func ToLatin(s string) string {
-	return parseBetaCode(s, true)
+	return parseBetaCode(s, ScriptLatin)
 }
 
-func parseBetaCode(s string, startLatin bool) string {
+func parseBetaCode(s string, start Script) string {
 	var out bytes.Buffer
 	upper := false
-	isLatin := startLatin
+	script := start
 	inQuot := false
 
 	var pDiacritics string
@@ -390,14 +215,20 @@ func parseBetaCode(s string, startLatin bool) string {
 		}
 
 		if handler, exists := bcmHandlers[r]; exists {
-			nextIdx, latinState, quotState := handler(runes, i, &out, isLatin, inQuot)
+			code, _ := parseCommand(runes, i)
+			nextIdx, latinState, quotState := handler(runes, i, &out, script == ScriptLatin, inQuot)
 			i = nextIdx
-			isLatin = latinState
+			switch {
+			case r == '$':
+				script = fontScript(code, start)
+			case latinState:
+				script = ScriptLatin
+			}
 			inQuot = quotState
 			continue
 		}
 
-		if !isLatin {
+		if script != ScriptLatin {
 			if r == '*' {
 				upper = true
 				// for uppercase, Diacritics should be prebuffered.
@@ -405,7 +236,30 @@ func parseBetaCode(s string, startLatin bool) string {
 				continue
 			}
 
-			if c, ok := GreekBase[unicode.ToLower(r)]; ok {
+			var c rune
+			var ok bool
+			diacritics := Diacritics
+			if script == ScriptCoptic {
+				c, ok = copticLetter(runes, &i)
+				diacritics = copticDiacritics
+			} else if c, ok = GreekBase[unicode.ToLower(r)]; ok && c == 'σ' && i+1 < len(runes) {
+				// S1, S2 and S3 force medial, final and lunate sigma.
+				switch runes[i+1] {
+				case '1':
+					if !upper {
+						c = medialSigma
+					}
+					i++
+				case '2':
+					c = 'ς'
+					i++
+				case '3':
+					c = 'ϲ'
+					i++
+				}
+			}
+
+			if ok {
 				if upper {
 					out.WriteRune(unicode.ToUpper(c))
 					upper = false
@@ -419,7 +273,7 @@ func parseBetaCode(s string, startLatin bool) string {
 				}
 				wasBase = true
 				continue
-			} else if d, ok := Diacritics[r]; ok {
+			} else if d, ok := diacritics[r]; ok {
 				if wasBase {
 					out.WriteString(d)
 				} else {
@@ -444,6 +298,7 @@ func parseBetaCode(s string, startLatin bool) string {
 
 	res := out.String()
 	res = regexp.MustCompile(`σ(\s|[[:punct:],·]|$)`).ReplaceAllString(res, "ς$1")
+	res = strings.ReplaceAll(res, string(medialSigma), "σ")
 	res = NormalizeGreek(res)
 	return res
 }
@@ -490,6 +345,10 @@ func NormalizeGreek(s string) string {
 
 			composed := Compose(base, dias)
 			out.WriteRune(composed)
+			if composed == base && unicode.Is(unicode.Coptic, base) {
+				// Coptic has no precomposed letters; keep the marks.
+				out.WriteString(string(dias))
+			}
 			i = j - 1
 			continue
 		}
@@ -530,6 +389,8 @@ func sortRunes(r []rune) {
 	}
 }
 
+// ToBetaCode gives the bare lower-case Beta Code used for lookups in the
+// analyses and lemmata files. EncodeBetaCode writes full Beta Code.
 func ToBetaCode(s string) string {
 	var out strings.Builder
 	for _, r := range s {
@@ -0,0 +1,52 @@
+package tlgcore
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestBetaCodeCommands decodes codes from the tables of the TLG Beta Code
+// Manual.
+func TestBetaCodeCommands(t *testing.T) {
+	tests := []struct {
+		beta, want string
+	}{
+		{"A#", "αʹ"},
+		{"#5A", "͵α"},
+		{"#1 #2 #3 #4", "ϟ ϛ ϙ ϡ"},
+		{"#12 #13", "— ※"},
+		{"#15 #17 #18", "> / <"},
+		{"% %13 %14", "† ‡ §"},
+		{"%1 %4 %10 %11", "? ! : •"},
+		{"%41%43", "-×"},
+		{"[1A]1 [2B]2 [3G]3 [4D]4", "(α) <β> {γ} ⟦δ⟧"},
+		{"[5E]5 [6Z]6", "⌊ε⌋ ⌈ζ⌉"},
+		{"\"6MH=NIN\"6", "«μῆνιν»"},
+	}
+	for _, tt := range tests {
+		if got := ToGreek(tt.beta); got != tt.want {
+			t.Errorf("ToGreek(%q) = %q, want %q", tt.beta, got, tt.want)
+		}
+		if u := UnknownBetaCodes(tt.beta); len(u) > 0 {
+			t.Errorf("UnknownBetaCodes(%q) = %q, want none", tt.beta, u)
+		}
+	}
+}
+
+func TestUnknownBetaCodes(t *testing.T) {
+	tests := []struct {
+		beta string
+		want []string
+	}{
+		{"MH=NIN A)/EIDE", nil},
+		{"A#100 B%20", []string{"#100", "%20"}},
+		{"#300 #400 @73", []string{"#300", "#400", "@73"}},
+		{"[20A]20", []string{"[20", "]20"}},
+		{"#1 #999 #1", []string{"#999"}},
+	}
+	for _, tt := range tests {
+		if got := UnknownBetaCodes(tt.beta); !slices.Equal(got, tt.want) {
+			t.Errorf("UnknownBetaCodes(%q) = %q, want %q", tt.beta, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package tlgcore
+
+// Glyphs of the Beta Code commands that stand for characters, keyed by the
+// command as written ("%13", "[4"), as the TLG Beta Code Manual assigns
+// them in its tables of page formatting (@), brackets ([ ]), additional
+// punctuation (%) and additional characters (#). Only assignments taken
+// from the manual are listed. Commands missing from a table decode to
+// nothing, except brackets, which fall back to [ and ]; UnknownBetaCodes
+// reports them.
+
+// betaPage holds the @ page and layout commands that produce spacing.
+var betaPage = map[string]string{
+	"@":   "  ",
+	"@6":  "", // blank line
+	"@70": " << ",
+	"@71": " >> ",
+}
+
+var betaOpenBrackets = map[string]string{
+	"[":  "[",
+	"[1": "(",
+	"[2": "<",
+	"[3": "{",
+	"[4": "⟦",
+	"[5": "⌊",
+	"[6": "⌈",
+	"[7": "⌈",
+	"[8": "⌊",
+	"[9": "˙",
+}
+
+var betaCloseBrackets = map[string]string{
+	"]":  "]",
+	"]1": ")",
+	"]2": ">",
+	"]3": "}",
+	"]4": "⟧",
+	"]5": "⌋",
+	"]6": "⌉",
+	"]7": "⌋",
+	"]8": "⌉",
+	"]9": "˙",
+}
+
+// betaPunct holds the % additional punctuation. %41 and %43 are the
+// metrical long and anceps.
+var betaPunct = map[string]string{
+	"%":    "†",
+	"%1":   "?",
+	"%2":   "*",
+	"%3":   "/",
+	"%4":   "!",
+	"%5":   "|",
+	"%6":   "=",
+	"%7":   "+",
+	"%8":   "%",
+	"%9":   "&",
+	"%10":  ":",
+	"%11":  "•",
+	"%12":  "*",
+	"%13":  "‡",
+	"%14":  "§",
+	"%18":  "'",
+	"%19":  "-",
+	"%41":  "-",
+	"%43":  "×",
+	"%103": "\\",
+	"%107": "~",
+}
+
+// betaChars holds the # additional characters: the numeral signs and the
+// letters used as numerals, and the critical signs of the manuscripts.
+var betaChars = map[string]string{
+	"#":   "ʹ", // numeral sign (keraia)
+	"#1":  "ϟ", // koppa
+	"#2":  "ϛ", // stigma
+	"#3":  "ϙ", // archaic koppa
+	"#4":  "ϡ", // sampi
+	"#5":  "͵", // lower numeral sign
+	"#12": "—",
+	"#13": "※",
+	"#15": ">", // diple
+	"#17": "/", // obelus
+	"#18": "<", // reversed diple
+}
+
+// betaQuotes holds the opening and closing glyphs of the " quotation
+// codes. Each code both opens and closes its quotation.
+var betaQuotes = map[string][2]string{
+	`"`:  {`"`, `"`},
+	`"1`: {`"`, `"`},
+	`"2`: {`"`, `"`},
+	`"3`: {"'", "'"},
+	`"4`: {"'", "'"},
+	`"5`: {"'", "'"},
+	`"6`: {"«", "»"},
+	`"7`: {"‹", "›"},
+	`"8`: {`"`, `"`},
+}
+
+// betaSpan is a font, format or markup command: what it means and the
+// glyphs, if any, that mark it in plain text.
+type betaSpan struct {
+	Name        string
+	Open, Close string
+}
+
+// betaFonts holds the $ (Greek) and & (Latin) font codes, keyed by number.
+// A font lasts until the next $ or &.
+var betaFonts = map[string]string{
+	"":   "normal",
+	"1":  "bold",
+	"2":  "bold italic",
+	"3":  "italic",
+	"4":  "superscript",
+	"5":  "subscript",
+	"6":  "superscript bold",
+	"7":  "subscript bold",
+	"8":  "superscript italic",
+	"9":  "subscript italic",
+	"10": "small",
+	"11": "small bold",
+	"12": "small italic",
+	"13": "small capitals",
+	"14": "small bold italic",
+	"20": "large",
+	"21": "large bold",
+	"22": "large italic",
+	"23": "large bold italic",
+	"30": "uncial",
+	"40": "open-face",
+	"50": "Coptic",
+}
+
+// betaFormats holds the < > text formatting codes, keyed by number.
+var betaFormats = map[string]betaSpan{
+	"":   {Name: "unspecified"},
+	"1":  {Name: "underline"},
+	"2":  {Name: "overline"},
+	"3":  {Name: "superscript"},
+	"4":  {Name: "subscript"},
+	"5":  {Name: "double underline"},
+	"6":  {Name: "letter-spaced"},
+	"7":  {Name: "struck through"},
+	"8":  {Name: "vertical"},
+	"9":  {Name: "boxed"},
+	"10": {Name: "upside down"},
+	"11": {Name: "reversed"},
+	"12": {Name: "ligature"},
+	"20": {Name: "angle", Open: "<", Close: ">"},
+	"30": {Name: "smaller"},
+	"31": {Name: "larger"},
+}
+
+// betaMarkup holds the { } markup codes, keyed by number.
+var betaMarkup = map[string]betaSpan{
+	"":   {Name: "unspecified", Open: " "},
+	"1":  {Name: "title"},
+	"2":  {Name: "marginalia"},
+	"3":  {Name: "lemma"},
+	"4":  {Name: "speaker"},
+	"5":  {Name: "stage direction"},
+	"6":  {Name: "heading"},
+	"7":  {Name: "subscription"},
+	"8":  {Name: "dedication"},
+	"9":  {Name: "catchword"},
+	"10": {Name: "citation"},
+	"11": {Name: "hypothesis"},
+	"40": {Name: "interlinear"},
+	"70": {Name: "editorial"},
+	"71": {Name: "translation"},
+}
+
+// BetaCodeKnown reports whether a Beta Code command, written as in the
+// text ("%13", "[4", "$1", "}70"), is in the tables.
+func BetaCodeKnown(code string) bool {
+	if code == "" {
+		return false
+	}
+	num := code[1:]
+	var ok bool
+	switch code[0] {
+	case '$', '&':
+		_, ok = betaFonts[num]
+	case '<', '>':
+		_, ok = betaFormats[num]
+	case '{', '}':
+		_, ok = betaMarkup[num]
+	case '@':
+		_, ok = betaPage[code]
+	case '[':
+		_, ok = betaOpenBrackets[code]
+	case ']':
+		_, ok = betaCloseBrackets[code]
+	case '"':
+		_, ok = betaQuotes[code]
+	case '%':
+		_, ok = betaPunct[code]
+	case '#':
+		_, ok = betaChars[code]
+	}
+	return ok
+}
+
+// UnknownBetaCodes returns the commands of s that are not in the tables,
+// in the order they occur.
+func UnknownBetaCodes(s string) []string {
+	var unknown []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if _, ok := bcmHandlers[runes[i]]; !ok {
+			continue
+		}
+		var code string
+		code, i = parseCommand(runes, i)
+		if !BetaCodeKnown(code) {
+			unknown = append(unknown, code)
+		}
+	}
+	return unknown
+}
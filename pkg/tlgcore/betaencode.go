@@ -0,0 +1,241 @@
+package tlgcore
+
+import (
+	"strings"
+	"unicode"
+)
+
+// betaEscapes holds the Beta Code of the ASCII characters that are
+// commands, or that are letters and diacritics in Greek text.
+var betaEscapes = map[rune]string{
+	'"':  `"`,
+	'%':  "%8",
+	'&':  "%9",
+	'(':  "[1",
+	')':  "]1",
+	'*':  "%2",
+	'+':  "%7",
+	'/':  "%3",
+	':':  "%10",
+	'<':  "#18",
+	'=':  "%6",
+	'>':  "#15",
+	'?':  "%1",
+	'[':  "[",
+	'\\': "%103",
+	']':  "]",
+	'\'': "%18",
+	'{':  "[3",
+	'|':  "%5",
+	'}':  "]3",
+}
+
+// betaGlyphs maps the non-ASCII glyphs of the %, # and bracket tables back
+// to their shortest command.
+var betaGlyphs = reverseBetaTables(betaPunct, betaChars, betaOpenBrackets, betaCloseBrackets)
+
+func reverseBetaTables(tables ...map[string]string) map[rune]string {
+	m := make(map[rune]string)
+	for _, t := range tables {
+		for code, g := range t {
+			r := []rune(g)
+			if len(r) != 1 || r[0] < 0x80 {
+				continue
+			}
+			if old, ok := m[r[0]]; ok && (len(old) < len(code) || len(old) == len(code) && old < code) {
+				continue
+			}
+			m[r[0]] = code
+		}
+	}
+	return m
+}
+
+// betaDiacritics maps the combining diacritics ToGreek decodes back to
+// their Beta Code. Macron and breve have none: % and & are commands.
+var betaDiacritics = map[rune]string{
+	'\u0313': ")",
+	'\u0314': "(",
+	'\u0308': "+",
+	'\u0301': "/",
+	'\u0300': "\\",
+	'\u0342': "=",
+	'\u0345': "|",
+}
+
+// EncodeBetaCode encodes Unicode Greek as canonical TLG Beta Code, such
+// that ToGreek gives s back: letters in upper case, capitals as * with
+// their breathing and accent before the letter, S1, S2 and S3 wherever
+// plain S would decode to another sigma, Latin runs between & and $, and
+// punctuation, quotation marks and brackets as their commands. Characters
+// with no Beta Code are kept as they are, which ToGreek passes through,
+// except #, $, @ and `, which cannot be written at all and are left out.
+func EncodeBetaCode(s string) string {
+	e := betaEncoder{runes: []rune(s)}
+	for e.i < len(e.runes) {
+		e.next()
+	}
+	return e.out.String()
+}
+
+type betaEncoder struct {
+	runes []rune
+	i     int
+	out   strings.Builder
+	latin bool
+	inQuo bool // inside a "6 or "7 quotation, as the decoder tracks it
+	sep   bool // a digit written next would be read as part of a command
+}
+
+// emit writes a token, separating it with ` from a preceding command
+// when it starts with a digit.
+func (e *betaEncoder) emit(tok string, command bool) {
+	if tok == "" {
+		return
+	}
+	if e.sep && tok[0] >= '0' && tok[0] <= '9' {
+		e.out.WriteByte('`')
+	}
+	e.out.WriteString(tok)
+	e.sep = command
+}
+
+func (e *betaEncoder) script(latin bool) {
+	if e.latin == latin {
+		return
+	}
+	e.latin = latin
+	if latin {
+		e.emit("&", true)
+	} else {
+		e.emit("$", true)
+	}
+}
+
+func (e *betaEncoder) next() {
+	r := e.runes[e.i]
+	e.i++
+
+	if tok, ok := e.letter(r); ok {
+		e.script(false)
+		e.emit(tok, strings.HasSuffix(tok, "S"))
+		return
+	}
+	if r < 0x80 && unicode.IsLetter(r) {
+		e.script(true)
+		e.emit(string(r), false)
+		return
+	}
+
+	switch r {
+	case '#', '$', '@', '`':
+		return
+	case '·', '’':
+		if !e.latin {
+			e.emit(map[rune]string{'·': ":", '’': "'"}[r], false)
+			return
+		}
+	case '«', '‹', '»', '›':
+		code, opens := `"6`, r == '«'
+		if r == '‹' || r == '›' {
+			code, opens = `"7`, r == '‹'
+		}
+		if opens != e.inQuo {
+			e.inQuo = opens
+			e.emit(code, true)
+			return
+		}
+	}
+
+	if tok, ok := betaEscapes[r]; ok && (!e.latin || !strings.ContainsRune("()*+/:=?\\|'", r)) {
+		e.emit(tok, true)
+		return
+	}
+	if tok, ok := betaGlyphs[r]; ok {
+		e.emit(tok, true)
+		return
+	}
+	e.emit(string(r), false)
+}
+
+// letter encodes a Greek letter with its diacritics, taking any combining
+// diacritics that follow it. It fails for anything else, and for letters
+// carrying a diacritic Beta Code cannot write.
+func (e *betaEncoder) letter(r rune) (string, bool) {
+	switch r {
+	case 'ϲ':
+		return "S3", true
+	case 'Ϲ':
+		return "*S3", true
+	}
+	beta, ok := AlphaBase[r]
+	if !ok {
+		return "", false
+	}
+	upper := strings.HasPrefix(beta, "*")
+	beta = strings.TrimPrefix(beta, "*")
+	if beta == "" || beta[0] < 'a' || beta[0] > 'z' || strings.ContainsAny(beta, "%&") {
+		return "", false
+	}
+
+	base, dias := beta[:1], beta[1:]
+	if base == "j" {
+		base = "s"
+	}
+	n := e.i
+	for n < len(e.runes) {
+		d, ok := betaDiacritics[e.runes[n]]
+		if !ok {
+			break
+		}
+		dias += d
+		n++
+	}
+	if n > e.i {
+		dr := []rune(dias)
+		sortBetaDiacritics(dr)
+		dias = string(dr)
+	}
+
+	if base == "s" {
+		final := e.finalPosition(n)
+		switch {
+		case r == 'ς' && !final:
+			base = "s2"
+		case r == 'σ' && final:
+			base = "s1"
+		}
+	}
+	e.i = n
+
+	base = strings.ToUpper(base)
+	if !upper {
+		return base + dias, true
+	}
+	sub := ""
+	if strings.HasSuffix(dias, "|") {
+		dias, sub = dias[:len(dias)-1], "|"
+	}
+	return "*" + dias + base + sub, true
+}
+
+// finalPosition reports whether a sigma followed by the rune at i is one
+// ToGreek writes as ς.
+func (e *betaEncoder) finalPosition(i int) bool {
+	if i >= len(e.runes) {
+		return true
+	}
+	r := e.runes[i]
+	return strings.ContainsRune("\t\n\f\r ,·", r) || r < 0x80 && unicode.IsPunct(r) || r < 0x80 && unicode.IsSymbol(r)
+}
+
+// sortBetaDiacritics puts Beta Code diacritics in canonical order:
+// breathing, diaeresis, accent, iota subscript.
+func sortBetaDiacritics(d []rune) {
+	order := func(r rune) int { return strings.IndexRune(")(+/\\=|", r) }
+	for i := 1; i < len(d); i++ {
+		for j := i; j > 0 && order(d[j-1]) > order(d[j]); j-- {
+			d[j-1], d[j] = d[j], d[j-1]
+		}
+	}
+}
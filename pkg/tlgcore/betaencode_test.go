@@ -0,0 +1,27 @@
+package tlgcore
+
+import "testing"
+
+// betaConformance is Greek that EncodeBetaCode must write so that ToGreek
+// reads it back unchanged: capitals, iota subscript, every sigma, Greek
+// punctuation, quotations, brackets, signs and Latin runs.
+var betaConformance = []string{
+	"μῆνιν ἄειδε θεὰ Πηληϊάδεω Ἀχιλῆος",
+	"Ἄνδρα μοι ἔννεπε, Μοῦσα, πολύτροπον, ὃς μάλα πολλὰ",
+	"ᾼδης ᾯ ᾠδή τῇ Ἅιδῃ ᾈ ῥ Ῥ ΐ ΰ",
+	"τίς; ὅς· λόγος. ϲοφία Ϲ ὁσ, ἐςτι σς σ1",
+	"«ἀλλὰ» ‹μή› \"ναί\" [τὸν] (δέ) {καί} ⟦φησί⟧ ⌊α⌋ <γ>",
+	"δ’ ἀπ’ οὐκ' ἔστ'",
+	"Cicero ait λόγος καὶ virtus 12 α%12 †3",
+	"— ※ ϛʹ ͵α ϟ ϡ × ⏑ ×",
+	"a/b (x) x*y 50% a&b c:d ? e=f g+h i|j k\\l",
+}
+
+func TestBetaCodeRoundTrip(t *testing.T) {
+	for _, s := range betaConformance {
+		beta := EncodeBetaCode(s)
+		if got := ToGreek(beta); got != s {
+			t.Errorf("ToGreek(EncodeBetaCode(%q)) = %q (Beta Code %q)", s, got, beta)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package tlgcore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// BetaKind is the kind of a node of a decoded Beta Code tree.
+type BetaKind int
+
+const (
+	BetaText    BetaKind = iota // run of text in one script
+	BetaQuote                   // quotation, "n ... "n
+	BetaBracket                 // editorial bracket, [n ... ]n
+	BetaFont                    // font, $n or &n up to the next font command
+	BetaFormat                  // text formatting, <n ... >n
+	BetaMarkup                  // markup, {n ... }n
+	BetaLayout                  // page and layout command, @n
+	BetaSymbol                  // punctuation or character, %n or #n
+	BetaUnknown                 // command with no known meaning
+)
+
+// Bracket is the editorial meaning of a BetaBracket span.
+type Bracket int
+
+const (
+	BracketOther      Bracket = iota
+	BracketSupplement         // [ ]: text restored by the editor
+	BracketLacuna             // [ ] holding only dots, dashes or spaces
+	BracketParen              // ( )
+	BracketAddition           // ⟨ ⟩: text added by the editor
+	BracketDeletion           // { }: text the editor deletes
+	BracketErasure            // ⟦ ⟧: text erased in the source
+	BracketUncertain          // ⌊ ⌋, ⌈ ⌉: doubtful text
+)
+
+// BetaNode is a node of a decoded Beta Code tree. Text, symbol, layout and
+// unknown nodes are leaves; the other kinds are spans with children.
+type BetaNode struct {
+	Kind     BetaKind
+	Code     string // command that opened the node, e.g. "[2", "$10", "%13"
+	Text     string // decoded text of text, symbol and layout nodes
+	Beta     string // Beta Code a text node was decoded from
+	Script   Script // script of a text node
+	Bracket  Bracket
+	Open     string // glyphs marking the span in plain text
+	Close    string
+	Children []*BetaNode
+
+	Continued bool // the span was opened before the decoded text began
+	Unclosed  bool // the span is still open where the decoded text ends
+}
+
+// BetaTree is a line of Beta Code decoded into a tree, keeping the markup
+// that ToGreek and ToLatin flatten into characters.
+type BetaTree struct {
+	Script Script // script the line started in
+	Nodes  []*BetaNode
+}
+
+// ParseBeta decodes a line of Beta Code into a tree. Spans closed without
+// being opened in s, as when a bracket runs over from the previous line,
+// hold everything before the closing command and are marked Continued;
+// spans left open at the end are marked Unclosed.
+func ParseBeta(s string, start Script) *BetaTree {
+	b := &betaBuilder{start: start, script: start}
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '`' {
+			continue
+		}
+		if _, ok := bcmHandlers[r]; !ok {
+			b.raw = append(b.raw, r)
+			continue
+		}
+		var code string
+		code, i = parseCommand(runes, i)
+		b.flush()
+		b.command(r, code)
+	}
+	b.flush()
+	for _, n := range b.stack {
+		n.Unclosed = true
+		b.finish(n)
+	}
+	return &BetaTree{Script: start, Nodes: b.root.Children}
+}
+
+type betaBuilder struct {
+	root   BetaNode
+	stack  []*BetaNode // open spans, innermost last
+	start  Script
+	script Script
+	raw    []rune // text not yet decoded
+}
+
+func (b *betaBuilder) container() *BetaNode {
+	if len(b.stack) > 0 {
+		return b.stack[len(b.stack)-1]
+	}
+	return &b.root
+}
+
+func (b *betaBuilder) add(n *BetaNode) {
+	c := b.container()
+	c.Children = append(c.Children, n)
+}
+
+func (b *betaBuilder) open(n *BetaNode) {
+	b.add(n)
+	b.stack = append(b.stack, n)
+}
+
+// flush decodes the pending text into a text node.
+func (b *betaBuilder) flush() {
+	if len(b.raw) == 0 {
+		return
+	}
+	raw := string(b.raw)
+	b.add(&BetaNode{Kind: BetaText, Text: parseBetaCode(raw, b.script), Beta: raw, Script: b.script})
+	b.raw = b.raw[:0]
+}
+
+func (b *betaBuilder) command(r rune, code string) {
+	switch r {
+	case '$', '&':
+		b.script = ScriptLatin
+		if r == '$' {
+			b.script = fontScript(code, b.start)
+		}
+		if n := len(b.stack); n > 0 && b.stack[n-1].Kind == BetaFont {
+			b.stack = b.stack[:n-1]
+		}
+		if len(code) > 1 {
+			b.open(&BetaNode{Kind: BetaFont, Code: code})
+		}
+	case '@':
+		if g, ok := betaPage[code]; ok {
+			b.add(&BetaNode{Kind: BetaLayout, Code: code, Text: g})
+		} else {
+			b.add(&BetaNode{Kind: BetaUnknown, Code: code})
+		}
+	case '{':
+		if code == "{70" {
+			b.script = ScriptLatin
+		}
+		b.open(&BetaNode{Kind: BetaMarkup, Code: code, Open: betaMarkup[code[1:]].Open})
+	case '}':
+		b.close(BetaMarkup, code, betaMarkup[code[1:]].Close)
+	case '<':
+		b.open(&BetaNode{Kind: BetaFormat, Code: code, Open: betaFormats[code[1:]].Open})
+	case '>':
+		b.close(BetaFormat, code, betaFormats[code[1:]].Close)
+	case '"':
+		q := betaQuotes[code]
+		for i := len(b.stack) - 1; i >= 0; i-- {
+			if b.stack[i].Kind == BetaQuote && b.stack[i].Code == code {
+				b.stack[i].Close = q[1]
+				b.stack = b.stack[:i]
+				return
+			}
+		}
+		b.open(&BetaNode{Kind: BetaQuote, Code: code, Open: q[0]})
+	case '[':
+		g, ok := betaOpenBrackets[code]
+		if !ok {
+			g = "["
+		}
+		b.open(&BetaNode{Kind: BetaBracket, Code: code, Bracket: bracketKind(code), Open: g})
+	case ']':
+		g, ok := betaCloseBrackets[code]
+		if !ok {
+			g = "]"
+		}
+		b.close(BetaBracket, code, g)
+	case '%':
+		b.symbol(betaPunct, code)
+	case '#':
+		b.symbol(betaChars, code)
+	}
+}
+
+func (b *betaBuilder) symbol(table map[string]string, code string) {
+	if g, ok := table[code]; ok {
+		b.add(&BetaNode{Kind: BetaSymbol, Code: code, Text: g})
+	} else {
+		b.add(&BetaNode{Kind: BetaUnknown, Code: code})
+	}
+}
+
+// close ends the innermost open span of kind whose command has the same
+// number as code. Without one, the span began before the text: everything
+// decoded so far in the current container becomes its content.
+func (b *betaBuilder) close(kind BetaKind, code, glyph string) {
+	num := code[1:]
+	for i := len(b.stack) - 1; i >= 0; i-- {
+		if n := b.stack[i]; n.Kind == kind && n.Code[1:] == num {
+			n.Close = glyph
+			for _, inner := range b.stack[i:] {
+				b.finish(inner)
+			}
+			b.stack = b.stack[:i]
+			return
+		}
+	}
+
+	opener := map[BetaKind]string{BetaBracket: "[", BetaMarkup: "{", BetaFormat: "<"}[kind]
+	c := b.container()
+	n := &BetaNode{Kind: kind, Code: opener + num, Close: glyph, Continued: true, Children: c.Children}
+	if kind == BetaBracket {
+		n.Bracket = bracketKind(n.Code)
+	}
+	c.Children = []*BetaNode{n}
+	b.finish(n)
+}
+
+// lacunaNumber matches a lacuna given by its extent, as in [.10] or
+// [ca. 10], in the Beta Code of the bracket.
+var lacunaNumber = regexp.MustCompile(`^\s*(?:(?i:ca?)\.?\s*|\.\s*)(\d+)\s*$`)
+
+// betaNodes is the Beta Code of a run of text nodes, or "" if the run
+// holds anything else.
+func betaNodes(nodes []*BetaNode) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		if n.Kind != BetaText {
+			return ""
+		}
+		sb.WriteString(n.Beta)
+	}
+	return sb.String()
+}
+
+// finish tells a lacuna from a supplement once the bracket's content is
+// known.
+func (b *betaBuilder) finish(n *BetaNode) {
+	if n.Kind != BetaBracket || n.Bracket != BracketSupplement {
+		return
+	}
+	if strings.Trim(plainNodes(n.Children), " .-–—‐") == "" || lacunaNumber.MatchString(betaNodes(n.Children)) {
+		n.Bracket = BracketLacuna
+	}
+}
+
+// Name is the meaning of a font, format or markup span from the Beta Code
+// tables ("bold", "underline", "title"), or "" when the code is unknown.
+func (n *BetaNode) Name() string {
+	if n.Code == "" {
+		return ""
+	}
+	num := n.Code[1:]
+	switch n.Kind {
+	case BetaFont:
+		return betaFonts[num]
+	case BetaFormat:
+		return betaFormats[num].Name
+	case BetaMarkup:
+		return betaMarkup[num].Name
+	}
+	return ""
+}
+
+func bracketKind(code string) Bracket {
+	switch code {
+	case "[":
+		return BracketSupplement
+	case "[1":
+		return BracketParen
+	case "[2":
+		return BracketAddition
+	case "[3":
+		return BracketDeletion
+	case "[4":
+		return BracketErasure
+	case "[5", "[6", "[7", "[8":
+		return BracketUncertain
+	}
+	return BracketOther
+}
+
+// Plain renders the tree as text, much as ToGreek and ToLatin do.
+func (t *BetaTree) Plain() string {
+	return plainNodes(t.Nodes)
+}
+
+func plainNodes(nodes []*BetaNode) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		switch n.Kind {
+		case BetaText, BetaSymbol, BetaLayout:
+			sb.WriteString(n.Text)
+		case BetaUnknown:
+		default:
+			sb.WriteString(n.Open)
+			sb.WriteString(plainNodes(n.Children))
+			sb.WriteString(n.Close)
+		}
+	}
+	return sb.String()
+}
+
+var bracketClasses = map[Bracket]string{
+	BracketOther:      "bracket",
+	BracketSupplement: "supplement",
+	BracketLacuna:     "lacuna",
+	BracketParen:      "parenthesis",
+	BracketAddition:   "addition",
+	BracketDeletion:   "deletion",
+	BracketErasure:    "erasure",
+	BracketUncertain:  "uncertain",
+}
+
+// HTML renders the tree as an HTML fragment. Spans become <span> elements
+// classed by kind ("quote", "supplement", "font", ...) with the Beta Code
+// command in data-code; text in the other script carries a lang attribute.
+func (t *BetaTree) HTML() string {
+	var sb strings.Builder
+	t.html(&sb, t.Nodes)
+	return sb.String()
+}
+
+func (t *BetaTree) html(sb *strings.Builder, nodes []*BetaNode) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case BetaText:
+			if n.Script != t.Script {
+				fmt.Fprintf(sb, `<span lang="%s">%s</span>`, n.Script.Lang(), teiEscape(n.Text))
+			} else {
+				sb.WriteString(teiEscape(n.Text))
+			}
+		case BetaSymbol, BetaLayout:
+			sb.WriteString(teiEscape(n.Text))
+		case BetaUnknown:
+			fmt.Fprintf(sb, `<span class="unknown" data-code="%s"></span>`, teiEscape(n.Code))
+		default:
+			class := map[BetaKind]string{BetaQuote: "quote", BetaFont: "font", BetaFormat: "format", BetaMarkup: "markup"}[n.Kind]
+			if n.Kind == BetaBracket {
+				class = bracketClasses[n.Bracket]
+			}
+			fmt.Fprintf(sb, `<span class="%s" data-code="%s">%s`, class, teiEscape(n.Code), teiEscape(n.Open))
+			t.html(sb, n.Children)
+			fmt.Fprintf(sb, "%s</span>", teiEscape(n.Close))
+		}
+	}
+}
+
+// TEI renders the tree as TEI P5 phrase-level markup: <q> for quotations,
+// <supplied>, <gap>, <surplus>, <del> and <unclear> for editorial
+// brackets, <hi> for fonts and formatting and <foreign> for text in the
+// other script. Unknown commands are dropped.
+func (t *BetaTree) TEI() string {
+	var sb strings.Builder
+	t.tei(&sb, t.Nodes)
+	return sb.String()
+}
+
+func (t *BetaTree) tei(sb *strings.Builder, nodes []*BetaNode) {
+	for _, n := range nodes {
+		var open, close string
+		switch n.Kind {
+		case BetaText:
+			if n.Script != t.Script {
+				fmt.Fprintf(sb, `<foreign xml:lang="%s">%s</foreign>`, n.Script.Lang(), teiEscape(n.Text))
+			} else {
+				sb.WriteString(teiEscape(n.Text))
+			}
+			continue
+		case BetaSymbol, BetaLayout:
+			sb.WriteString(teiEscape(n.Text))
+			continue
+		case BetaUnknown:
+			continue
+		case BetaQuote:
+			open, close = "<q>", "</q>"
+		case BetaFont, BetaFormat:
+			rend := n.Name()
+			if rend == "" {
+				rend = n.Code
+			}
+			open, close = fmt.Sprintf(`<hi rend="%s">`, teiEscape(rend)), "</hi>"
+		case BetaMarkup:
+			typ := n.Name()
+			if typ == "" {
+				typ = "betacode"
+			}
+			open, close = fmt.Sprintf(`<seg type="%s" n="%s">`, teiEscape(typ), teiEscape(n.Code)), "</seg>"
+		case BetaBracket:
+			switch n.Bracket {
+			case BracketSupplement:
+				open, close = `<supplied reason="lost">`, "</supplied>"
+			case BracketLacuna:
+				sb.WriteString(`<gap reason="lost"/>`)
+				continue
+			case BracketAddition:
+				open, close = `<supplied reason="omitted">`, "</supplied>"
+			case BracketDeletion:
+				open, close = "<surplus>", "</surplus>"
+			case BracketErasure:
+				open, close = `<del rend="erasure">`, "</del>"
+			case BracketUncertain:
+				open, close = "<unclear>", "</unclear>"
+			default:
+				open, close = teiEscape(n.Open), teiEscape(n.Close)
+			}
+		}
+		sb.WriteString(open)
+		t.tei(sb, n.Children)
+		sb.WriteString(close)
+	}
+}
+
+// ANSI SGR on/off pairs for the spans that are styled in a terminal.
+var ansiBrackets = map[Bracket][2]string{
+	BracketSupplement: {"\x1b[3m", "\x1b[23m"},
+	BracketLacuna:     {"\x1b[2m", "\x1b[22m"},
+	BracketAddition:   {"\x1b[3m", "\x1b[23m"},
+	BracketDeletion:   {"\x1b[9m", "\x1b[29m"},
+	BracketErasure:    {"\x1b[9m", "\x1b[29m"},
+	BracketUncertain:  {"\x1b[4m", "\x1b[24m"},
+}
+
+// ANSI renders the tree as plain text for a terminal, with supplements in
+// italics, deletions struck through, doubtful text underlined and unknown
+// commands, spans included, shown raw in reverse video.
+func (t *BetaTree) ANSI() string {
+	var sb strings.Builder
+	ansiNodes(&sb, t.Nodes)
+	return sb.String()
+}
+
+func ansiNodes(sb *strings.Builder, nodes []*BetaNode) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case BetaText, BetaSymbol, BetaLayout:
+			sb.WriteString(n.Text)
+		case BetaUnknown:
+			fmt.Fprintf(sb, "\x1b[7m%s\x1b[27m", n.Code)
+		default:
+			sgr := ansiBrackets[n.Bracket]
+			if n.Kind != BetaBracket {
+				sgr = [2]string{}
+			}
+			if !n.Continued && !BetaCodeKnown(n.Code) {
+				fmt.Fprintf(sb, "\x1b[7m%s\x1b[27m", n.Code)
+			}
+			sb.WriteString(n.Open + sgr[0])
+			ansiNodes(sb, n.Children)
+			sb.WriteString(sgr[1] + n.Close)
+		}
+	}
+}
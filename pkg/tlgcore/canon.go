@@ -3,6 +3,8 @@ package tlgcore
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"strconv"
 	"strings"
@@ -29,7 +31,21 @@ func GetBiblioFromCanon(canonPath string, tlgID string, workID string) (string,
 	}
 	defer f.Close()
 
-	p := NewParser(f)
+	return BiblioFromCanon(f, tlgID, workID)
+}
+
+func GetBiblioFromCanonFS(fsys fs.FS, name string, tlgID string, workID string) (string, error) {
+	r, err := OpenReaderAt(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	defer closeReader(r)
+
+	return BiblioFromCanon(r, tlgID, workID)
+}
+
+func BiblioFromCanon(r io.ReaderAt, tlgID string, workID string) (string, error) {
+	p := NewParser(r)
 	fullText, err := p.ExtractAllText()
 	if err != nil {
 		return "", fmt.Errorf("canon parse error: %v", err)
@@ -118,7 +134,11 @@ func GetMetadataFromCanonDB(dbPath string, tlgID string, workID string) ([]Canon
 	}
 	defer f.Close()
 
-	p := NewParser(f)
+	return MetadataFromCanonDB(f, tlgID, workID)
+}
+
+func MetadataFromCanonDB(r io.ReaderAt, tlgID string, workID string) ([]CanonField, error) {
+	p := NewParser(r)
 	fullText, err := p.ExtractAllText()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,126 @@
+package tlgcore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// citationLevels returns the distinct level characters of a work's
+// citation schema in the order formatCitation prints them.
+func citationLevels(meta *WorkMetadata) []string {
+	if meta == nil {
+		return nil
+	}
+	var levels []string
+	seen := make(map[string]bool)
+	for _, def := range meta.Citations {
+		if !seen[def.LevelChar] {
+			levels = append(levels, def.LevelChar)
+			seen[def.LevelChar] = true
+		}
+	}
+	return levels
+}
+
+func citationLabels(meta *WorkMetadata) string {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, def := range meta.Citations {
+		if !seen[def.LevelChar] {
+			labels = append(labels, def.Label)
+			seen[def.LevelChar] = true
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// ParseCitation splits a dotted citation such as "2.34" into its parts and
+// checks it against the work's citation schema. A citation may name only
+// the leading levels ("2" for all of book 2).
+func ParseCitation(meta *WorkMetadata, s string) ([]string, error) {
+	if meta == nil || len(meta.Citations) == 0 {
+		return nil, fmt.Errorf("no citation schema available for this work")
+	}
+	levels := citationLevels(meta)
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty citation")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > len(levels) {
+		return nil, fmt.Errorf("citation %q has %d levels, but work %s is cited by %s",
+			s, len(parts), meta.ID, citationLabels(meta))
+	}
+	for i, pt := range parts {
+		if pt == "" {
+			return nil, fmt.Errorf("citation %q: empty value at level %d", s, i+1)
+		}
+		for _, r := range pt {
+			if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') {
+				return nil, fmt.Errorf("citation %q: invalid character %q", s, r)
+			}
+		}
+	}
+	return parts, nil
+}
+
+// compareCitation compares a full citation with a (possibly partial)
+// target, looking only at the levels the target names.
+func compareCitation(cit, target []string) int {
+	for i := range target {
+		v := ""
+		if i < len(cit) {
+			v = cit[i]
+		}
+		if c := compareCitationPart(v, target[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareCitationPart orders values like "9" < "10" < "10a" < "b".
+func compareCitationPart(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+
+	na, ra := splitNumeric(a)
+	nb, rb := splitNumeric(b)
+	switch {
+	case na >= 0 && nb < 0:
+		return -1
+	case na < 0 && nb >= 0:
+		return 1
+	case na != nb:
+		if na < nb {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(strings.ToLower(ra), strings.ToLower(rb))
+}
+
+func splitNumeric(s string) (int, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return -1, s
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return -1, s
+	}
+	return n, s[i:]
+}
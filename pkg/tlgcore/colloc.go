@@ -0,0 +1,166 @@
+package tlgcore
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Collocate is a word (or lemma) found near a node word, with the usual
+// association scores. Expected is the co-occurrence count to be expected
+// if the collocate were spread evenly over the text.
+type Collocate struct {
+	Key      string
+	Observed int // occurrences inside the node's windows
+	Freq     int // occurrences in the whole text
+	Expected float64
+	MI       float64 // mutual information, log2(O/E)
+	TScore   float64 // (O-E)/sqrt(O)
+	LogLik   float64 // Dunning's G² over the 2x2 contingency table
+}
+
+// CollocationCounter counts the units (forms or lemmata) that occur within
+// Window tokens on either side of a node word. Windows do not cross work
+// boundaries, a position inside overlapping windows counts once, and the
+// node itself never counts, even inside the window of another node. Feed
+// it every token of the text under study, in order.
+type CollocationCounter struct {
+	Window int
+	IsNode func(Token) bool
+	Units  func(Token) []string // keys a token counts as; several if ambiguous
+
+	Freq  *FreqTable     // frequency of every unit in the text
+	Nodes int            // occurrences of the node
+	Slots int            // positions inside node windows
+	Cooc  map[string]int // unit -> occurrences inside node windows
+
+	work    string
+	history []collocToken
+	right   int // tokens still to come inside the last node's window
+}
+
+type collocToken struct {
+	units   []string
+	counted bool
+}
+
+func NewCollocationCounter(window int, isNode func(Token) bool, units func(Token) []string) *CollocationCounter {
+	return &CollocationCounter{
+		Window: window,
+		IsNode: isNode,
+		Units:  units,
+		Freq:   NewFreqTable(),
+		Cooc:   make(map[string]int),
+	}
+}
+
+// Add counts one token of the text.
+func (c *CollocationCounter) Add(tok Token) {
+	if tok.Line.WorkID != c.work {
+		c.work = tok.Line.WorkID
+		c.history = c.history[:0]
+		c.right = 0
+	}
+
+	node := c.IsNode(tok)
+	cur := collocToken{units: c.Units(tok), counted: node}
+	c.Freq.AddShared(cur.units, 1)
+
+	if c.right > 0 {
+		if !cur.counted {
+			c.count(cur.units)
+			cur.counted = true
+		}
+		c.right--
+	}
+
+	if node {
+		c.Nodes++
+		for i := range c.history {
+			if !c.history[i].counted {
+				c.count(c.history[i].units)
+				c.history[i].counted = true
+			}
+		}
+		c.right = c.Window
+	}
+
+	c.history = append(c.history, cur)
+	if len(c.history) > c.Window {
+		c.history = c.history[1:]
+	}
+}
+
+func (c *CollocationCounter) count(units []string) {
+	c.Slots++
+	for _, u := range units {
+		c.Cooc[u]++
+	}
+}
+
+// Collocates scores every unit seen at least minCount times near the node.
+func (c *CollocationCounter) Collocates(minCount int) []Collocate {
+	n := float64(c.Freq.Total)
+	r1 := float64(c.Slots)
+
+	var result []Collocate
+	for key, o := range c.Cooc {
+		if o < minCount {
+			continue
+		}
+		f := c.Freq.Count(key)
+		obs := float64(o)
+		exp := r1 * float64(f) / n
+
+		// Contingency table: inside/outside the windows by unit/other.
+		o11 := obs
+		o12 := max(r1-obs, 0)
+		o21 := max(float64(f)-obs, 0)
+		o22 := max(n-r1-o21, 0)
+		ll := 0.0
+		for _, cell := range [][3]float64{
+			{o11, r1, o11 + o21},
+			{o12, r1, o12 + o22},
+			{o21, o21 + o22, o11 + o21},
+			{o22, o21 + o22, o12 + o22},
+		} {
+			if cell[0] > 0 {
+				ll += cell[0] * math.Log(cell[0]*n/(cell[1]*cell[2]))
+			}
+		}
+
+		result = append(result, Collocate{
+			Key:      key,
+			Observed: o,
+			Freq:     f,
+			Expected: exp,
+			MI:       math.Log2(obs / exp),
+			TScore:   (obs - exp) / math.Sqrt(obs),
+			LogLik:   2 * ll,
+		})
+	}
+	return result
+}
+
+// SortCollocates orders collocates by "mi", "t" or "ll", highest first.
+func SortCollocates(cs []Collocate, by string) error {
+	var score func(Collocate) float64
+	switch by {
+	case "mi":
+		score = func(c Collocate) float64 { return c.MI }
+	case "t":
+		score = func(c Collocate) float64 { return c.TScore }
+	case "ll":
+		score = func(c Collocate) float64 { return c.LogLik }
+	default:
+		return fmt.Errorf("unknown score %q (want mi, t or ll)", by)
+	}
+	slices.SortFunc(cs, func(a, b Collocate) int {
+		if c := cmp.Compare(score(b), score(a)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return nil
+}
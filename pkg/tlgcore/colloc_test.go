@@ -0,0 +1,64 @@
+package tlgcore
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestCollocationCounter(t *testing.T) {
+	tests := []struct {
+		name   string
+		works  []string // one text per work, words separated by spaces
+		window int
+		slots  int
+		want   map[string]int
+	}{
+		{
+			name:   "single node",
+			works:  []string{"a b n c d e"},
+			window: 2,
+			slots:  4,
+			want:   map[string]int{"a": 1, "b": 1, "c": 1, "d": 1},
+		},
+		{
+			name:   "overlapping windows",
+			works:  []string{"a b n c n d e f"},
+			window: 2,
+			slots:  5,
+			want:   map[string]int{"a": 1, "b": 1, "c": 1, "d": 1, "e": 1},
+		},
+		{
+			name:   "adjacent nodes",
+			works:  []string{"a n n b"},
+			window: 1,
+			slots:  2,
+			want:   map[string]int{"a": 1, "b": 1},
+		},
+		{
+			name:   "work boundary",
+			works:  []string{"a b n", "c d"},
+			window: 2,
+			slots:  2,
+			want:   map[string]int{"a": 1, "b": 1},
+		},
+	}
+	for _, tt := range tests {
+		c := NewCollocationCounter(tt.window,
+			func(tok Token) bool { return tok.Key == "n" },
+			func(tok Token) []string { return []string{tok.Key} })
+		nodes := 0
+		for i, text := range tt.works {
+			line := &Line{WorkID: string(rune('1' + i))}
+			for pos, w := range strings.Fields(text) {
+				c.Add(Token{Text: w, Key: w, Pos: pos, Line: line})
+				if w == "n" {
+					nodes++
+				}
+			}
+		}
+		if c.Nodes != nodes || c.Slots != tt.slots || !maps.Equal(c.Cooc, tt.want) {
+			t.Errorf("%s: nodes %d, slots %d, cooc %v; want %d, %d, %v", tt.name, c.Nodes, c.Slots, c.Cooc, nodes, tt.slots, tt.want)
+		}
+	}
+}
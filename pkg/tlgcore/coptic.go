@@ -0,0 +1,56 @@
+package tlgcore
+
+import "unicode"
+
+// copticBase maps Beta Code to the Coptic alphabet, for text in the $50
+// font and in COP files. The letters follow the Greek ones; V is sou.
+var copticBase = map[rune]rune{
+	'a': 'ⲁ', 'b': 'ⲃ', 'g': 'ⲅ', 'd': 'ⲇ', 'e': 'ⲉ', 'v': 'ⲋ', 'z': 'ⲍ',
+	'h': 'ⲏ', 'q': 'ⲑ', 'i': 'ⲓ', 'k': 'ⲕ', 'l': 'ⲗ', 'm': 'ⲙ', 'n': 'ⲛ',
+	'c': 'ⲝ', 'o': 'ⲟ', 'p': 'ⲡ', 'r': 'ⲣ', 's': 'ⲥ', 't': 'ⲧ', 'u': 'ⲩ',
+	'f': 'ⲫ', 'x': 'ⲭ', 'y': 'ⲯ', 'w': 'ⲱ',
+	':': '·', '?': ';', '\'': '’',
+}
+
+// copticExtra holds the letters of Demotic origin, written as a Beta Code
+// letter and a number.
+var copticExtra = map[string]rune{
+	"s1": 'ϣ', // shai
+	"f1": 'ϥ', // fai
+	"x1": 'ϧ', // khei
+	"h1": 'ϩ', // hori
+	"g1": 'ϫ', // gangia
+	"k1": 'ϭ', // shima
+	"t1": 'ϯ', // ti
+	"x2": 'ⳉ', // Akhmimic khei
+}
+
+// copticDiacritics maps Beta Code diacritics in Coptic text. = is the
+// supralinear stroke, written over each letter it covers.
+var copticDiacritics = map[rune]string{
+	'=':  "\u0305",
+	'/':  "\u0301",
+	'\\': "\u0300",
+	'+':  "\u0308",
+	')':  "\u2CF1",
+	'(':  "\u2CF0",
+}
+
+// copticLetter decodes the Coptic letter at runes[*i], moving *i past the
+// number of a two-character code.
+func copticLetter(runes []rune, i *int) (rune, bool) {
+	r := unicode.ToLower(runes[*i])
+	if *i+1 < len(runes) && unicode.IsDigit(runes[*i+1]) {
+		if c, ok := copticExtra[string([]rune{r, runes[*i+1]})]; ok {
+			*i++
+			return c, true
+		}
+	}
+	c, ok := copticBase[r]
+	return c, ok
+}
+
+// ToCoptic decodes Beta Code that starts in the Coptic script.
+func ToCoptic(s string) string {
+	return parseBetaCode(s, ScriptCoptic)
+}
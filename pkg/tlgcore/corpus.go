@@ -0,0 +1,283 @@
+package tlgcore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Corpus is a TLG-E or PHI-5 directory: authtab.dir, doccan1.txt and a
+// pair of tlgNNNN.txt / tlgNNNN.idt (or latNNNN) files per author. Files
+// may come from disk, an archive or memory through fs.FS, and be named in
+// lower or upper case. Author tables and IDTs are read once and kept, so a
+// Corpus may serve many requests.
+type Corpus struct {
+	FS fs.FS
+
+	mu    sync.Mutex
+	names map[string]map[string]string
+	idts  map[string]map[string]*WorkMetadata
+}
+
+func NewCorpus(fsys fs.FS) *Corpus {
+	return &Corpus{FS: fsys}
+}
+
+func OpenCorpus(dir string) *Corpus {
+	return NewCorpus(os.DirFS(dir))
+}
+
+func (c *Corpus) AuthorTable() ([]AuthorRecord, error) {
+	return ReadAuthorTableFS(c.FS, corpusFile(c.FS, "authtab.dir"))
+}
+
+// AuthorNames maps the author IDs in dir/authtab.dir ("TLG0012") to
+// author names. A missing table yields an empty map. The map is shared by
+// later calls and must not be modified.
+func (c *Corpus) AuthorNames(dir string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if names, ok := c.names[dir]; ok {
+		return names
+	}
+	names := make(map[string]string)
+	if records, err := ReadAuthorTableFS(c.FS, corpusFile(c.FS, path.Join(dir, "authtab.dir"))); err == nil {
+		for _, rec := range records {
+			names[strings.ToUpper(strings.TrimSpace(rec.ID))] = rec.Name
+		}
+	}
+	if c.names == nil {
+		c.names = make(map[string]map[string]string)
+	}
+	c.names[dir] = names
+	return names
+}
+
+// Author returns the ID ("TLG0012") and the name of the author of a text
+// file such as "TLG-E/tlg0012", from the authtab.dir beside it. An author
+// missing from the table is named by the ID.
+func (c *Corpus) Author(textID string) (id, name string) {
+	dir, base := path.Split(textID)
+	id = strings.ToUpper(base)
+	if name, ok := c.AuthorNames(dir)[id]; ok {
+		return id, name
+	}
+	return id, id
+}
+
+// Title returns the title of a work from the IDT of its text file, or
+// "(Unknown Title)".
+func (c *Corpus) Title(textID, workID string) string {
+	if idt, err := c.IDT(textID); err == nil && idt[workID] != nil {
+		return idt[workID].Title
+	}
+	return "(Unknown Title)"
+}
+
+// TextKinds are the file name prefixes of the text files Texts lists:
+// tlg (TLG), lat and phi (PHI 5), civ (PHI 7 Latin), cop (Coptic), ddp
+// (papyri) and ins (inscriptions).
+var TextKinds = []string{"tlg", "lat", "phi", "civ", "cop", "ddp", "ins"}
+
+// TextKindUsage is the usage of the -kind flag.
+const TextKindUsage = "comma-separated kinds of text file to read: tlg, lat, phi, civ, cop, ddp or ins (default tlg, or lat with -lat)"
+
+// TextKind returns the kind of a text file name such as "ddp0001.txt", or
+// "" if it is not a corpus text file.
+func TextKind(name string) string {
+	base := strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+	for _, kind := range TextKinds {
+		num := strings.TrimPrefix(base, kind)
+		if num != base && num != "" && isNumeric(num) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// ParseTextKinds reads a -kind list such as "tlg,ddp,ins". An empty list
+// means tlg, or lat if latin is set.
+func ParseTextKinds(list string, latin bool) ([]string, error) {
+	if list == "" {
+		if latin {
+			return []string{"lat"}, nil
+		}
+		return []string{"tlg"}, nil
+	}
+	var kinds []string
+	for _, k := range strings.Split(list, ",") {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if !slices.Contains(TextKinds, k) {
+			return nil, fmt.Errorf("unknown text kind %q (want %s)", k, strings.Join(TextKinds, ", "))
+		}
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+// Texts lists the text files of the given kinds, or of every kind in
+// TextKinds if none is given, anywhere under the corpus root, as paths
+// without extension such as "tlg0012" or "TLG-E/tlg0012".
+func (c *Corpus) Texts(kinds ...string) ([]string, error) {
+	var names []string
+	err := fs.WalkDir(c.FS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.ToLower(path.Ext(d.Name())) != ".txt" {
+			return nil
+		}
+		kind := TextKind(d.Name())
+		if kind == "" || len(kinds) > 0 && !slices.Contains(kinds, kind) {
+			return nil
+		}
+		names = append(names, strings.TrimSuffix(p, path.Ext(p)))
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// IDT reads the IDT file of an author, e.g. "tlg0012". The result is
+// shared by later calls and must not be modified.
+func (c *Corpus) IDT(authorID string) (map[string]*WorkMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if idt, ok := c.idts[authorID]; ok {
+		return idt, nil
+	}
+	idt, err := ReadIDTFS(c.FS, textPath(c.FS, authorID, ".idt"))
+	if err != nil {
+		return nil, err
+	}
+	if c.idts == nil {
+		c.idts = make(map[string]map[string]*WorkMetadata)
+	}
+	c.idts[authorID] = idt
+	return idt, nil
+}
+
+func (c *Corpus) Biblio(authorID, workID string) (string, error) {
+	dir, base := path.Split(authorID)
+	return GetBiblioFromCanonFS(c.FS, corpusFile(c.FS, path.Join(dir, "doccan1.txt")), authorNumber(base), workID)
+}
+
+// Open returns a parser for an author's text file with its IDT loaded.
+// The caller must Close the parser. A missing IDT is not an error; the
+// parser then works without titles and citation labels.
+func (c *Corpus) Open(authorID string) (*Parser, error) {
+	r, err := OpenReaderAt(c.FS, textPath(c.FS, authorID, ".txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewParser(r)
+	p.IsLatinFile = IsLatinFileName(path.Base(authorID))
+	p.IsCopticFile = IsCopticFileName(path.Base(authorID))
+	p.IsDocumentFile = IsDocumentFileName(path.Base(authorID))
+	if idt, err := c.IDT(authorID); err == nil {
+		p.IDTData = idt
+	} else {
+		p.IDTData = make(map[string]*WorkMetadata)
+	}
+	return p, nil
+}
+
+// OpenReaderAt opens name in fsys for random access. Files that do not
+// implement io.ReaderAt are read into memory.
+func OpenReaderAt(fsys fs.FS, name string) (io.ReaderAt, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if ra, ok := f.(io.ReaderAt); ok {
+		return ra, nil
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func closeReader(r io.ReaderAt) {
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// IsLatinFileName reports whether a corpus file name such as "lat0474.txt"
+// holds Latin text.
+func IsLatinFileName(name string) bool {
+	for _, pref := range []string{"LAT", "CIV", "PHI"} {
+		if strings.HasPrefix(strings.ToUpper(name), pref) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCopticFileName reports whether a corpus file name such as "cop0001.txt"
+// holds Coptic text.
+func IsCopticFileName(name string) bool {
+	return strings.HasPrefix(strings.ToUpper(name), "COP")
+}
+
+// IsDocumentFileName reports whether a corpus file name such as
+// "ddp0001.txt" (papyri) or "ins0001.txt" (inscriptions) holds the
+// documents of PHI 7.
+func IsDocumentFileName(name string) bool {
+	for _, pref := range []string{"DDP", "INS"} {
+		if strings.HasPrefix(strings.ToUpper(name), pref) {
+			return true
+		}
+	}
+	return false
+}
+
+// textPath maps "TLG-E/TLG0012" to "TLG-E/tlg0012.txt", or to the file
+// of that name in another case, such as the "TLG-E/TLG0012.TXT" of the
+// discs.
+func textPath(fsys fs.FS, authorID, ext string) string {
+	dir, base := path.Split(authorID)
+	return corpusFile(fsys, dir+strings.ToLower(base)+ext)
+}
+
+// corpusFile returns name, or if no such file exists, the file in the
+// same directory whose name differs from it only in case.
+func corpusFile(fsys fs.FS, name string) string {
+	if _, err := fs.Stat(fsys, name); err == nil {
+		return name
+	}
+	dir, base := path.Split(name)
+	entries, err := fs.ReadDir(fsys, path.Clean(dir))
+	if err != nil {
+		return name
+	}
+	for _, e := range entries {
+		if strings.EqualFold(e.Name(), base) {
+			return dir + e.Name()
+		}
+	}
+	return name
+}
+
+// authorNumber strips the corpus prefix from an author ID ("tlg0012" -> "0012").
+func authorNumber(authorID string) string {
+	id := strings.ToUpper(authorID)
+	for _, pref := range []string{"TLG", "LAT", "CIV", "PHI", "COP", "DDP", "INS"} {
+		if strings.HasPrefix(id, pref) {
+			return id[len(pref):]
+		}
+	}
+	return id
+}
@@ -0,0 +1,58 @@
+package tlgcore
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// TestCorpusUpperCase reads a corpus named as on the TLG-E disc.
+func TestCorpusUpperCase(t *testing.T) {
+	text, idt := testCitedText(7, testCitedWorks...)
+	c := NewCorpus(fstest.MapFS{
+		"TLG-E/AUTHTAB.DIR": &fstest.MapFile{Data: []byte("TLG9999 &1Homerus Testis&\xff")},
+		"TLG-E/TLG9999.TXT": &fstest.MapFile{Data: text},
+		"TLG-E/TLG9999.IDT": &fstest.MapFile{Data: idt},
+		"TLG-E/LAT9999.TXT": &fstest.MapFile{Data: text},
+	})
+
+	texts, err := c.Texts("tlg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"TLG-E/TLG9999"}; !slices.Equal(texts, want) {
+		t.Fatalf("Texts = %q, want %q", texts, want)
+	}
+
+	for _, id := range []string{"TLG-E/TLG9999", "TLG-E/tlg9999"} {
+		p, err := c.Open(id)
+		if err != nil {
+			t.Errorf("Open(%q): %v", id, err)
+			continue
+		}
+		if p.IDTData["2"] == nil {
+			t.Errorf("Open(%q): IDT not read", id)
+		}
+		n := 0
+		for _, err := range p.Lines("") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		p.Close()
+		if n != 70 {
+			t.Errorf("Open(%q): %d lines, want 70", id, n)
+		}
+	}
+
+	if found, err := c.FindText("tlg9999"); err != nil {
+		t.Errorf("FindText: %v", err)
+	} else if _, err := c.Open(found); err != nil {
+		t.Errorf("Open(FindText) = Open(%q): %v", found, err)
+	}
+
+	if id, name := c.Author("TLG-E/TLG9999"); id != "TLG9999" || name != "Homerus Testis" {
+		t.Errorf("Author = %q, %q", id, name)
+	}
+}
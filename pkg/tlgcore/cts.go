@@ -0,0 +1,211 @@
+package tlgcore
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// URN is a CTS URN for a TLG or PHI work or passage, such as
+// urn:cts:greekLit:tlg0012.tlg001:1.1-1.10. TLG authors belong to the
+// greekLit namespace; PHI-5 authors (lat*.txt) are phi in latinLit. Works
+// are numbered like the text group: tlg001 is work 1 of its author.
+type URN struct {
+	Namespace string // "greekLit" or "latinLit"
+	TextGroup string // e.g. "tlg0012"
+	Work      string // e.g. "tlg001"
+	Version   string // edition, if given; not used to resolve
+	From, To  string // citation range, both empty for the whole work
+}
+
+// ParseURN parses a CTS URN. A passage may be a single citation or a
+// range; subreferences (@word) are ignored.
+func ParseURN(s string) (URN, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 4 || len(parts) > 5 || !strings.EqualFold(parts[0], "urn") || !strings.EqualFold(parts[1], "cts") {
+		return URN{}, fmt.Errorf("invalid CTS URN %q: want urn:cts:namespace:textgroup.work[:passage]", s)
+	}
+
+	u := URN{Namespace: parts[2]}
+	if u.Namespace != "greekLit" && u.Namespace != "latinLit" {
+		return URN{}, fmt.Errorf("CTS URN %q: unknown namespace %q (want greekLit or latinLit)", s, u.Namespace)
+	}
+
+	work := strings.Split(parts[3], ".")
+	if len(work) < 2 || len(work) > 3 || work[0] == "" || work[1] == "" {
+		return URN{}, fmt.Errorf("CTS URN %q: work must be textgroup.work[.version]", s)
+	}
+	u.TextGroup, u.Work = work[0], work[1]
+	if len(work) == 3 {
+		u.Version = work[2]
+	}
+	if _, err := urnNumber(u.TextGroup); err != nil {
+		return URN{}, fmt.Errorf("CTS URN %q: %v", s, err)
+	}
+	if _, err := urnNumber(u.Work); err != nil {
+		return URN{}, fmt.Errorf("CTS URN %q: %v", s, err)
+	}
+
+	if len(parts) == 5 && parts[4] != "" {
+		from, to, isRange := strings.Cut(parts[4], "-")
+		var err error
+		if u.From, err = urnCitation(from); err != nil {
+			return URN{}, fmt.Errorf("CTS URN %q: %v", s, err)
+		}
+		u.To = u.From
+		if isRange {
+			if u.To, err = urnCitation(to); err != nil {
+				return URN{}, fmt.Errorf("CTS URN %q: %v", s, err)
+			}
+		}
+		if u.From == "" || u.To == "" {
+			return URN{}, fmt.Errorf("CTS URN %q: empty citation in passage", s)
+		}
+	}
+	return u, nil
+}
+
+func urnCitation(s string) (string, error) {
+	s, _, _ = strings.Cut(s, "@")
+	return url.PathUnescape(s)
+}
+
+// urnNumber splits the number off an identifier such as "tlg0012".
+func urnNumber(id string) (int, error) {
+	i := strings.IndexFunc(id, func(r rune) bool { return r >= '0' && r <= '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("identifier %q has no corpus prefix and number", id)
+	}
+	n, err := strconv.Atoi(id[i:])
+	if err != nil {
+		return 0, fmt.Errorf("identifier %q has no corpus prefix and number", id)
+	}
+	return n, nil
+}
+
+// WorkURN returns the URN of a work in a text file, e.g. ("tlg0012", "1")
+// or ("TLG-E/TLG0012", "1") for urn:cts:greekLit:tlg0012.tlg001.
+func WorkURN(textID, workID string) URN {
+	base := strings.ToLower(path.Base(textID))
+	u := URN{Namespace: "greekLit"}
+	prefix := strings.TrimRight(base, "0123456789")
+	if IsLatinFileName(base) {
+		u.Namespace = "latinLit"
+		if prefix == "lat" {
+			prefix = "phi"
+		}
+	}
+	u.TextGroup = prefix + base[len(prefix):]
+
+	if n, err := strconv.Atoi(workID); err == nil {
+		u.Work = fmt.Sprintf("%s%03d", prefix, n)
+	} else {
+		u.Work = prefix + workID
+	}
+	return u
+}
+
+// CitationURN returns the URN of one line, citation being its
+// FormattedCitation.
+func CitationURN(textID, workID, citation string) string {
+	u := WorkURN(textID, workID)
+	u.From, u.To = citation, citation
+	return u.String()
+}
+
+func (u URN) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "urn:cts:%s:%s.%s", u.Namespace, u.TextGroup, u.Work)
+	if u.Version != "" {
+		b.WriteString("." + u.Version)
+	}
+	if u.From != "" {
+		b.WriteString(":" + urnEscape(u.From))
+		if u.To != "" && u.To != u.From {
+			b.WriteString("-" + urnEscape(u.To))
+		}
+	}
+	return b.String()
+}
+
+// urnEscape percent-encodes what may not stand in a CTS passage.
+func urnEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// TextID returns the text file the URN names, without extension:
+// tlg0012 for greekLit tlg0012, lat0448 for latinLit phi0448.
+func (u URN) TextID() string {
+	n, _ := urnNumber(u.TextGroup)
+	prefix := strings.TrimRight(strings.ToLower(u.TextGroup), "0123456789")
+	if prefix == "phi" {
+		prefix = "lat"
+	}
+	return fmt.Sprintf("%s%04d", prefix, n)
+}
+
+// WorkID returns the IDT work ID the URN names ("1" for tlg001).
+func (u URN) WorkID() string {
+	n, _ := urnNumber(u.Work)
+	return strconv.Itoa(n)
+}
+
+// Resolve returns the decoded lines of the work or passage a URN names.
+// The text file is looked for at the corpus root and then anywhere below
+// it.
+func (c *Corpus) Resolve(urn string) ([]Line, error) {
+	u, err := ParseURN(urn)
+	if err != nil {
+		return nil, err
+	}
+	textID, err := c.FindText(u.TextID())
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := c.Open(textID)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	lines, err := p.PassageLines(u.WorkID(), u.From, u.To)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", u, err)
+	}
+	return lines, nil
+}
+
+// FindText returns the path of the text file of an author, such as
+// "TLG-E/tlg0012" for "tlg0012".
+func (c *Corpus) FindText(textID string) (string, error) {
+	if _, err := fs.Stat(c.FS, textPath(c.FS, textID, ".txt")); err == nil {
+		return textID, nil
+	}
+	var kinds []string
+	if kind := TextKind(textID); kind != "" {
+		kinds = append(kinds, kind)
+	}
+	texts, err := c.Texts(kinds...)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range texts {
+		if strings.EqualFold(path.Base(t), textID) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("text %s: %w", textID, fs.ErrNotExist)
+}
@@ -0,0 +1,187 @@
+package tlgcore
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DictEntry is one entry of the LSJ (grc.lsj.xml) or Lewis & Short
+// (lat.ls.perseus-eng1.xml) dictionary. Key is the key attribute of the
+// entry, in Beta Code for LSJ; Headword is the entry's head word as shown
+// to the reader. Sense is the entry as plain text, see ProcessSense.
+type DictEntry struct {
+	Key      string `json:"key"`
+	Headword string `json:"headword"`
+	Sense    string `json:"sense"`
+}
+
+type dictXMLEntry struct {
+	Key   string `xml:"key,attr"`
+	Head  string `xml:"head"`
+	Orth  string `xml:"orth"`
+	Sense string `xml:",innerxml"`
+}
+
+// LoadDictIndex reads a dictionary index written by indexer, which maps
+// normalized headwords to byte offsets in the dictionary XML.
+func LoadDictIndex(path string) (map[string]int64, error) {
+	index := make(map[string]int64)
+	f, err := os.Open(path)
+	if err != nil {
+		return index, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Split(line, " => ")
+		if len(parts) == 2 {
+			key := strings.Trim(parts[0], "'")
+			offset, _ := strconv.ParseInt(parts[1], 10, 64)
+			index[key] = offset
+		}
+	}
+	return index, scanner.Err()
+}
+
+// LookupDict returns the dictionary entries for a lemma as found in the
+// analyses files. Entries whose offsets are already in seen are skipped,
+// and the offsets of the entries returned are added to it.
+func LookupDict(xmlPath string, rawLemma string, index map[string]int64, seen map[int64]bool, isGreek bool) ([]DictEntry, error) {
+	var strictKey string
+
+	fields := strings.Fields(rawLemma)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	lemma := fields[0]
+
+	if isGreek {
+		strictKey = NormalizeStrict(lemma)
+	} else {
+		strictKey = NormalizeLatin(lemma)
+	}
+
+	fuzzyKey := NormalizeFuzzy(lemma)
+
+	var offsets []int64
+	localSeen := make(map[int64]bool)
+
+	addUnique := func(val int64) {
+		if !localSeen[val] {
+			offsets = append(offsets, val)
+			localSeen[val] = true
+		}
+	}
+
+	if val, ok := index[strictKey]; ok {
+		addUnique(val)
+	}
+
+	// Check numbered keys (e.g., "legw2", "legw3", ...) ... Do we need this?
+	for i := 2; ; i++ {
+		key := strictKey + strconv.Itoa(i)
+		val, ok := index[key]
+		if !ok {
+			break
+		}
+		addUnique(val)
+	}
+
+	if len(offsets) == 0 {
+		if val, ok := index[fuzzyKey]; ok {
+			addUnique(val)
+		} else {
+			for k, off := range index {
+				if strings.HasPrefix(k, fuzzyKey) {
+					addUnique(off)
+					break
+				}
+			}
+		}
+	}
+
+	if len(offsets) == 0 {
+		return nil, nil
+	}
+
+	f, err := os.Open(xmlPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening dictionary: %v", err)
+	}
+	defer f.Close()
+
+	var entries []DictEntry
+	for _, offset := range offsets {
+		if seen[offset] {
+			continue
+		}
+
+		if _, err := f.Seek(offset, 0); err != nil {
+			return entries, fmt.Errorf("seek error: %v", err)
+		}
+
+		decoder := xml.NewDecoder(f)
+		var entry dictXMLEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return entries, nil
+		}
+
+		seen[offset] = true
+
+		head := strings.TrimSpace(entry.Head)
+		if head == "" {
+			head = strings.TrimSpace(entry.Orth)
+		}
+		if head == "" {
+			head = strings.TrimRight(entry.Key, "0123456789")
+		}
+		if isGreek {
+			head = ToGreek(head)
+		}
+		entries = append(entries, DictEntry{Key: entry.Key, Headword: head, Sense: ProcessSense(entry.Sense)})
+	}
+	return entries, nil
+}
+
+var (
+	senseForeign = regexp.MustCompile(`<foreign lang="greek">([^<]+)</foreign>`)
+	senseTags    = regexp.MustCompile("<[^>]*>")
+	senseSpace   = regexp.MustCompile(`\s+`)
+)
+
+// ProcessSense turns the XML of a dictionary entry into plain text, with
+// Greek decoded and one paragraph per sense.
+func ProcessSense(rawXml string) string {
+	processed := senseForeign.ReplaceAllStringFunc(rawXml, func(match string) string {
+		code := senseForeign.FindStringSubmatch(match)[1]
+		return ToGreek(code)
+	})
+
+	processed = strings.ReplaceAll(processed, "<sense", "\n\n  • <sense")
+	processed = strings.ReplaceAll(processed, "</bibl>", " ")
+	processed = strings.ReplaceAll(processed, "</cit>", " ")
+
+	clean := senseTags.ReplaceAllString(processed, "")
+	clean = strings.ReplaceAll(clean, "&gt;", ">")
+	clean = strings.ReplaceAll(clean, "&lt;", "<")
+	clean = strings.ReplaceAll(clean, "&amp;", "&")
+	clean = strings.ReplaceAll(clean, "&quot;", "\"")
+
+	lines := strings.Split(clean, "\n")
+	var finalLines []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" {
+			finalLines = append(finalLines, senseSpace.ReplaceAllString(trimmed, " "))
+		}
+	}
+
+	return strings.Join(finalLines, "\n\n")
+}
@@ -0,0 +1,288 @@
+package tlgcore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FoldPolicy selects which distinctions of polytonic Greek survive when
+// words and search patterns are normalized for matching. The zero value
+// ignores accents, breathings, case, sigma forms, iota subscript and
+// diaeresis.
+type FoldPolicy struct {
+	Accents    bool // keep acute, grave and circumflex
+	Breathings bool // keep smooth and rough breathing
+	Case       bool // keep upper/lower case
+	Sigma      bool // keep medial, final and lunate sigma apart
+	Subscript  bool // keep iota subscript
+	Diaeresis  bool // keep diaeresis
+	Adscript   bool // spell iota subscript as adscript iota (ᾳ = αι)
+}
+
+// oxiaToTonos maps the Greek Extended vowels with oxia to the canonically
+// equivalent Greek and Coptic vowels with tonos.
+var oxiaToTonos = map[rune]rune{
+	'\u1F71': '\u03AC', '\u1F73': '\u03AD', '\u1F75': '\u03AE', '\u1F77': '\u03AF',
+	'\u1F79': '\u03CC', '\u1F7B': '\u03CD', '\u1F7D': '\u03CE', '\u1FBB': '\u0386',
+	'\u1FC9': '\u0388', '\u1FCB': '\u0389', '\u1FDB': '\u038A', '\u1FF9': '\u038C',
+	'\u1FEB': '\u038E', '\u1FFB': '\u038F', '\u1FD3': '\u0390', '\u1FE3': '\u03B0',
+}
+
+// decompositions inverts UnicodeComposition.
+var decompositions = func() map[rune]string {
+	m := make(map[rune]string, len(UnicodeComposition))
+	for key, val := range UnicodeComposition {
+		m[val] = key
+	}
+	return m
+}()
+
+// Decompose splits a precomposed Greek letter into its base letter and
+// combining diacritics, in the order used by UnicodeComposition.
+func Decompose(r rune) (rune, []rune) {
+	if t, ok := oxiaToTonos[r]; ok {
+		r = t
+	}
+	key, ok := decompositions[r]
+	if !ok {
+		return r, nil
+	}
+	runes := []rune(key)
+	return runes[0], runes[1:]
+}
+
+func (f FoldPolicy) keepMark(m rune) bool {
+	switch m {
+	case '\u0301', '\u0300', '\u0342':
+		return f.Accents
+	case '\u0313', '\u0314':
+		return f.Breathings
+	case '\u0308':
+		return f.Diaeresis
+	case '\u0345':
+		return f.Subscript && !f.Adscript
+	}
+	return false
+}
+
+// Fold normalizes decoded text according to the policy.
+func (f FoldPolicy) Fold(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			if f.keepMark(r) {
+				out.WriteRune(r)
+			}
+			continue
+		}
+
+		base, marks := Decompose(r)
+		if !f.Case {
+			base = unicode.ToLower(base)
+		}
+		if !f.Sigma {
+			switch base {
+			case 'ς', 'ϲ':
+				base = 'σ'
+			case 'Ϲ':
+				base = 'Σ'
+			}
+		}
+
+		var kept []rune
+		adscript := false
+		for _, m := range marks {
+			if m == '\u0345' && f.Adscript {
+				adscript = true
+			} else if f.keepMark(m) {
+				kept = append(kept, m)
+			}
+		}
+
+		out.WriteRune(Compose(base, kept))
+		if adscript {
+			if unicode.IsUpper(base) {
+				out.WriteRune('Ι')
+			} else {
+				out.WriteRune('ι')
+			}
+		}
+	}
+	return out.String()
+}
+
+// WordPattern matches whole words against a wildcard or regular
+// expression after both have been folded by the same policy.
+type WordPattern struct {
+	Policy FoldPolicy
+	re     *regexp.Regexp
+}
+
+// CompileWordPattern compiles a wildcard pattern (φιλο*, with * for any
+// run of letters and ? for one letter) or, with isRegex, a regular
+// expression such as λ[ιυ]σ.*. The pattern must match the entire word and
+// may be typed in any OutputForm.
+func CompileWordPattern(pat string, isRegex bool, pol FoldPolicy) (*WordPattern, error) {
+	pat = NFC.Apply(pat)
+	var expr strings.Builder
+	expr.WriteString("^(?:")
+
+	if isRegex {
+		// Fold only the literal letters of the pattern, leaving escapes
+		// such as \S or \p{Greek}, group names and flags alone.
+		runes := []rune(pat)
+		for i := 0; i < len(runes); i++ {
+			r := runes[i]
+			if r == '(' && i+1 < len(runes) && runes[i+1] == '?' {
+				j := i + 2
+				for j < len(runes) && !strings.ContainsRune(">:)", runes[j]) {
+					j++
+				}
+				j = min(j, len(runes)-1)
+				expr.WriteString(string(runes[i : j+1]))
+				i = j
+				continue
+			}
+			if r == '\\' && i+1 < len(runes) {
+				j := i + 1
+				if (runes[j] == 'p' || runes[j] == 'P') && j+1 < len(runes) && runes[j+1] == '{' {
+					for j < len(runes) && runes[j] != '}' {
+						j++
+					}
+				}
+				if j >= len(runes) {
+					j = len(runes) - 1
+				}
+				expr.WriteString(string(runes[i : j+1]))
+				i = j
+				continue
+			}
+			if r == '[' {
+				class, j := pol.foldClass(runes, i)
+				expr.WriteString(class)
+				i = j
+				continue
+			}
+			if strings.ContainsRune(`.+*?|(){}^$`, r) {
+				expr.WriteRune(r)
+				continue
+			}
+			expr.WriteString(pol.foldLiteral(r))
+		}
+	} else {
+		for _, r := range pat {
+			switch r {
+			case '*':
+				expr.WriteString(`\pL*`)
+			case '?':
+				expr.WriteString(`\pL`)
+			default:
+				expr.WriteString(regexp.QuoteMeta(pol.Fold(string(r))))
+			}
+		}
+	}
+	expr.WriteString(")$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pat, err)
+	}
+	return &WordPattern{Policy: pol, re: re}, nil
+}
+
+// foldLiteral folds a letter of a regular expression as one atom, so that
+// a repetition after it applies to all of its fold, such as the αι of ᾳ
+// with Adscript.
+func (f FoldPolicy) foldLiteral(r rune) string {
+	folded := f.Fold(string(r))
+	if utf8.RuneCountInString(folded) == 1 {
+		return regexp.QuoteMeta(folded)
+	}
+	return "(?:" + regexp.QuoteMeta(folded) + ")"
+}
+
+// foldClass folds the members of the character class that opens at
+// runes[i] and returns it with the index of its closing bracket. A member
+// that folds to several letters, such as ᾳ with Adscript, cannot stand in
+// a class: it becomes an alternative to the class, or is left out of a
+// negated class, since folded text never holds it as one letter.
+func (f FoldPolicy) foldClass(runes []rune, i int) (string, int) {
+	j := i + 1
+	negated := j < len(runes) && runes[j] == '^'
+	if negated {
+		j++
+	}
+	var members strings.Builder
+	var alts []string
+	add := func(r rune) string {
+		folded := []rune(f.Fold(string(r)))
+		switch {
+		case len(folded) == 1:
+			if strings.ContainsRune(`\]-^[`, folded[0]) {
+				return `\` + string(folded)
+			}
+			return string(folded)
+		case len(folded) > 1:
+			alts = append(alts, regexp.QuoteMeta(string(folded)))
+		}
+		return ""
+	}
+	for start := j; j < len(runes); j++ {
+		r := runes[j]
+		switch {
+		case r == ']' && j > start:
+			class := "[" + members.String() + "]"
+			if negated {
+				class = "[^" + members.String() + "]"
+				if members.Len() == 0 {
+					class = "."
+				}
+				return class, j
+			}
+			if len(alts) == 0 {
+				return class, j
+			}
+			if members.Len() > 0 {
+				alts = append([]string{class}, alts...)
+			}
+			return "(?:" + strings.Join(alts, "|") + ")", j
+		case r == '\\' && j+1 < len(runes):
+			k := j + 1
+			if (runes[k] == 'p' || runes[k] == 'P') && k+1 < len(runes) && runes[k+1] == '{' {
+				for k < len(runes) && runes[k] != '}' {
+					k++
+				}
+			}
+			k = min(k, len(runes)-1)
+			members.WriteString(string(runes[j : k+1]))
+			j = k
+		case r == '[' && j+1 < len(runes) && runes[j+1] == ':':
+			k := j + 2
+			for k+1 < len(runes) && !(runes[k] == ':' && runes[k+1] == ']') {
+				k++
+			}
+			k = min(k+1, len(runes)-1)
+			members.WriteString(string(runes[j : k+1]))
+			j = k
+		case j+2 < len(runes) && runes[j+1] == '-' && runes[j+2] != ']':
+			lo, hi := add(runes[j]), add(runes[j+2])
+			if lo != "" && hi != "" {
+				members.WriteString(lo + "-" + hi)
+			} else {
+				members.WriteString(lo + hi)
+			}
+			j += 2
+		default:
+			members.WriteString(add(r))
+		}
+	}
+	// No closing bracket: leave the pattern for regexp to reject.
+	return string(runes[i:]), len(runes) - 1
+}
+
+func (wp *WordPattern) Match(word string) bool {
+	return wp.re.MatchString(wp.Policy.Fold(word))
+}
@@ -0,0 +1,94 @@
+package tlgcore
+
+import "testing"
+
+func TestFold(t *testing.T) {
+	all := FoldPolicy{Accents: true, Breathings: true, Case: true, Sigma: true, Subscript: true, Diaeresis: true}
+	tests := []struct {
+		name string
+		pol  FoldPolicy
+		in   string
+		want string
+	}{
+		{"zero", FoldPolicy{}, "Ἀχαιοῖς", "αχαιοισ"},
+		{"zero", FoldPolicy{}, "ᾠδῇ", "ωδη"},
+		{"zero", FoldPolicy{}, "Πηληϊάδεω", "πηληιαδεω"},
+		{"zero", FoldPolicy{}, "ΣΟΦΟΣ ϲοφὸς", "σοφοσ σοφοσ"},
+		{"accents", FoldPolicy{Accents: true}, "Ἀχαιοῖς", "αχαιοῖσ"},
+		{"accents", FoldPolicy{Accents: true}, "σοφὸς", "σοφὸσ"},
+		{"accents", FoldPolicy{Accents: true}, "ᾠδῇ", "ωδῆ"},
+		{"breathings", FoldPolicy{Breathings: true}, "Ἀχαιοῖς", "ἀχαιοισ"},
+		{"breathings", FoldPolicy{Breathings: true}, "ᾠδῇ", "ὠδη"},
+		{"case", FoldPolicy{Case: true}, "Ἀχαιοῖς", "Αχαιοισ"},
+		{"case", FoldPolicy{Case: true}, "ΣΟΦΟΣ ϲοφὸς", "ΣΟΦΟΣ σοφοσ"},
+		{"sigma", FoldPolicy{Sigma: true}, "ΣΟΦΟΣ ϲοφὸς", "σοφοσ ϲοφος"},
+		{"subscript", FoldPolicy{Subscript: true}, "ᾠδῇ", "ῳδῃ"},
+		{"subscript", FoldPolicy{Subscript: true}, "ᾼ", "ᾳ"},
+		{"diaeresis", FoldPolicy{Diaeresis: true}, "Πηληϊάδεω", "πηληϊαδεω"},
+		{"adscript", FoldPolicy{Adscript: true}, "ᾠδῇ", "ωιδηι"},
+		{"adscript", FoldPolicy{Subscript: true, Adscript: true}, "ᾠδῇ", "ωιδηι"},
+		{"adscript", FoldPolicy{Case: true, Adscript: true}, "ᾼ", "ΑΙ"},
+		{"all", all, "Ἀχαιοῖς", "Ἀχαιοῖς"},
+		{"all", all, "ᾠδῇ", "ᾠδῇ"},
+		{"all", all, "Πηληϊάδεω", "Πηληϊάδεω"},
+		{"all", all, "ΣΟΦΟΣ ϲοφὸς", "ΣΟΦΟΣ ϲοφὸς"},
+		// Oxia folds like tonos.
+		{"oxia", FoldPolicy{Accents: true}, "\u1F71", "\u03AC"},
+		{"oxia", FoldPolicy{}, "\u1F71", "α"},
+		// Latin text passes through.
+		{"latin", FoldPolicy{}, "Arma", "arma"},
+	}
+	for _, tt := range tests {
+		if got := tt.pol.Fold(tt.in); got != tt.want {
+			t.Errorf("%s: Fold(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWordPattern(t *testing.T) {
+	tests := []struct {
+		pat   string
+		regex bool
+		pol   FoldPolicy
+		word  string
+		want  bool
+	}{
+		{"φιλο*", false, FoldPolicy{}, "φιλόσοφος", true},
+		{"φιλο*", false, FoldPolicy{}, "φίλτρον", false},
+		{"φιλο*", false, FoldPolicy{Accents: true}, "φιλόσοφος", false},
+		{"λ?γος", false, FoldPolicy{}, "λόγος", true},
+		{"λ?γος", false, FoldPolicy{}, "λγος", false},
+		{"ἀχαι*", false, FoldPolicy{}, "Ἀχαιοῖς", true},
+		{"ἀχαι*", false, FoldPolicy{Breathings: true}, "Ἀχαιοῖς", true},
+		{"ἁχαι*", false, FoldPolicy{Breathings: true}, "Ἀχαιοῖς", false},
+		{"λ[ιυ]σ.*", true, FoldPolicy{}, "λύσις", true},
+		{"λ[ιυ]σ.*", true, FoldPolicy{}, "λάσις", false},
+		{"λ[ίύ]σ.*", true, FoldPolicy{}, "λυσις", true},
+		{"[ά-ω]ς", true, FoldPolicy{}, "ἒς", true},
+		{"[^ᾳ]ς", true, FoldPolicy{}, "ας", false},
+		{"[^ᾳ]ς", true, FoldPolicy{}, "ες", true},
+		{"ᾳ[ς]", true, FoldPolicy{Adscript: true}, "αις", true},
+		{"[ᾳη]ς", true, FoldPolicy{Adscript: true}, "αις", true},
+		{"[ᾳη]ς", true, FoldPolicy{Adscript: true}, "ης", true},
+		{`[\p{Greek}]+`, true, FoldPolicy{}, "λόγος", true},
+		{"(?P<stem>λόγ)ος", true, FoldPolicy{}, "λόγος", true},
+		{"(?i)Λόγος", true, FoldPolicy{Case: true}, "λογος", true},
+		{"λ(?:ό|ε)γος", true, FoldPolicy{}, "λεγος", true},
+		{"ᾳ+", true, FoldPolicy{Adscript: true}, "αιαι", true},
+		{"ᾳ+", true, FoldPolicy{Adscript: true}, "αιι", false},
+	}
+	for _, tt := range tests {
+		wp, err := CompileWordPattern(tt.pat, tt.regex, tt.pol)
+		if err != nil {
+			t.Errorf("CompileWordPattern(%q, %v): %v", tt.pat, tt.regex, err)
+			continue
+		}
+		if got := wp.Match(tt.word); got != tt.want {
+			t.Errorf("CompileWordPattern(%q, %v, %+v).Match(%q) = %v, want %v", tt.pat, tt.regex, tt.pol, tt.word, got, tt.want)
+		}
+	}
+
+	if _, err := CompileWordPattern("λ[ιυ", true, FoldPolicy{}); err == nil {
+		t.Error("CompileWordPattern accepted an unclosed class")
+	}
+}
@@ -0,0 +1,101 @@
+package tlgcore
+
+import (
+	"cmp"
+	"slices"
+)
+
+// FreqEntry is one row of a frequency table. Ambiguous counts the
+// occurrences that were credited to this entry and to others as well,
+// e.g. a form that belongs to two lemmata.
+type FreqEntry struct {
+	Key       string
+	Count     int
+	Ambiguous int
+}
+
+// FreqTable counts word forms or lemmata over a stream of tokens.
+type FreqTable struct {
+	Total   int // tokens counted, each once
+	entries map[string]*FreqEntry
+}
+
+func NewFreqTable() *FreqTable {
+	return &FreqTable{entries: make(map[string]*FreqEntry)}
+}
+
+// Add counts n occurrences of key.
+func (t *FreqTable) Add(key string, n int) {
+	t.entry(key).Count += n
+	t.Total += n
+}
+
+// AddShared counts n occurrences that may stand for any of keys, such as
+// a form that belongs to more than one lemma. Each key is credited with
+// all n, but Total grows only by n. With no keys only Total grows.
+func (t *FreqTable) AddShared(keys []string, n int) {
+	for _, k := range keys {
+		e := t.entry(k)
+		e.Count += n
+		if len(keys) > 1 {
+			e.Ambiguous += n
+		}
+	}
+	t.Total += n
+}
+
+func (t *FreqTable) entry(key string) *FreqEntry {
+	e, ok := t.entries[key]
+	if !ok {
+		e = &FreqEntry{Key: key}
+		t.entries[key] = e
+	}
+	return e
+}
+
+// Count returns the occurrences of key.
+func (t *FreqTable) Count(key string) int {
+	if e, ok := t.entries[key]; ok {
+		return e.Count
+	}
+	return 0
+}
+
+// Types returns the number of distinct keys.
+func (t *FreqTable) Types() int {
+	return len(t.entries)
+}
+
+// Hapax returns the number of keys that occur exactly once.
+func (t *FreqTable) Hapax() int {
+	n := 0
+	for _, e := range t.entries {
+		if e.Count == 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// Rate returns count per 10,000 tokens of the table.
+func (t *FreqTable) Rate(count int) float64 {
+	if t.Total == 0 {
+		return 0
+	}
+	return float64(count) * 10000 / float64(t.Total)
+}
+
+// Ranked returns the entries by descending count, ties in key order.
+func (t *FreqTable) Ranked() []FreqEntry {
+	ranked := make([]FreqEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		ranked = append(ranked, *e)
+	}
+	slices.SortFunc(ranked, func(a, b FreqEntry) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return ranked
+}
@@ -171,6 +171,24 @@ func main() {
 	for _, e := range compEntries {
 		buf.WriteString(fmt.Sprintf("\t%q: %#x,\n", e.key, e.val))
 	}
+	buf.WriteString("}\n\n")
+
+	fmt.Println("Generating CanonicalDecomposition...")
+	buf.WriteString("var CanonicalDecomposition = map[rune]string{\n")
+	for _, rng := range [][]rune{{0x00C0, 0x024F}, {0x0340, 0x03FF}, {0x1E00, 0x1FFF}} {
+		for r := rng[0]; r <= rng[1]; r++ {
+			if d, ok := decompMap[r]; ok {
+				buf.WriteString(fmt.Sprintf("\t%#x: %q,\n", r, string(d)))
+			}
+		}
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Println("Generating CombiningClass...")
+	buf.WriteString("var CombiningClass = map[rune]uint8{\n")
+	for _, c := range parseCombiningClasses(input) {
+		buf.WriteString(fmt.Sprintf("\t%#x: %d,\n", c[0], c[1]))
+	}
 	buf.WriteString("}\n")
 
 	src, err := format.Source(buf.Bytes())
@@ -215,6 +233,30 @@ func parseUnicodeData(path string) map[rune][]rune {
 	return m
 }
 
+// parseCombiningClasses lists the characters with a nonzero canonical
+// combining class, with their class.
+func parseCombiningClasses(path string) [][2]rune {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	var classes [][2]rune
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), ";")
+		if len(parts) < 4 {
+			continue
+		}
+		r, _ := strconv.ParseInt(parts[0], 16, 32)
+		ccc, _ := strconv.Atoi(parts[3])
+		if ccc != 0 {
+			classes = append(classes, [2]rune{rune(r), rune(ccc)})
+		}
+	}
+	return classes
+}
+
 func dec(r rune, dm map[rune][]rune) []rune {
 	if parts, ok := dm[r]; ok {
 		var res []rune
@@ -0,0 +1,510 @@
+package tlgcore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// The corpus index is a single file:
+//
+//	magic | postings | line tables | docs | terms | sparse terms | footer
+//
+// A doc is one work of one text file. Each term (a WordKey) points at its
+// postings, a run of (doc, token position, line) triples delta-encoded as
+// uvarints. Line tables hold the block and formatted citation of every
+// line of a doc. Only the docs and every 64th term are loaded on open; the
+// rest is read on demand.
+const (
+	indexMagic      = "LYCIDX01"
+	indexSparseStep = 64
+	indexFooterSize = 8 * 4
+)
+
+// Posting is one occurrence of a word in the corpus.
+type Posting struct {
+	TextID   string // text file without extension, e.g. "tlg0012"
+	WorkID   string
+	Block    int
+	Line     int // ordinal of the line within the work
+	Citation string
+	Pos      int // ordinal of the token within the work
+}
+
+type indexDoc struct {
+	textID   string
+	workID   string
+	linesOff int64
+	linesLen int64
+}
+
+type termPostings struct {
+	buf      []byte
+	n        int
+	lastDoc  int
+	lastPos  int
+	lastLine int
+}
+
+func (t *termPostings) add(doc, pos, line int) {
+	if doc != t.lastDoc {
+		t.buf = binary.AppendUvarint(t.buf, uint64(doc-t.lastDoc))
+		t.lastDoc, t.lastPos, t.lastLine = doc, 0, 0
+	} else {
+		t.buf = binary.AppendUvarint(t.buf, 0)
+	}
+	t.buf = binary.AppendUvarint(t.buf, uint64(pos-t.lastPos))
+	t.buf = binary.AppendUvarint(t.buf, uint64(line-t.lastLine))
+	t.lastPos, t.lastLine = pos, line
+	t.n++
+}
+
+// IndexBuilder accumulates the postings of a corpus in memory.
+type IndexBuilder struct {
+	docs  []indexDoc
+	lines bytes.Buffer
+	terms map[string]*termPostings
+}
+
+func NewIndexBuilder() *IndexBuilder {
+	return &IndexBuilder{terms: make(map[string]*termPostings)}
+}
+
+// AddText indexes every work of a corpus text file, e.g. "tlg0012".
+func (b *IndexBuilder) AddText(corpus *Corpus, textID string) error {
+	p, err := corpus.Open(textID)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	doc := -1
+	line := -1
+	var lastLine *Line
+	var lineBuf []byte
+
+	finish := func() {
+		if doc < 0 {
+			return
+		}
+		d := &b.docs[doc]
+		d.linesOff = int64(b.lines.Len())
+		d.linesLen = int64(len(lineBuf))
+		b.lines.Write(lineBuf)
+		lineBuf = lineBuf[:0]
+	}
+
+	for tok, err := range p.Tokens("") {
+		if err != nil {
+			return err
+		}
+		if doc < 0 || tok.Line.WorkID != b.docs[doc].workID {
+			finish()
+			b.docs = append(b.docs, indexDoc{textID: textID, workID: tok.Line.WorkID})
+			doc = len(b.docs) - 1
+			line = -1
+			lastLine = nil
+		}
+		if tok.Line != lastLine {
+			lastLine = tok.Line
+			line++
+			lineBuf = binary.AppendUvarint(lineBuf, uint64(tok.Line.Block))
+			lineBuf = appendString(lineBuf, tok.Line.FormattedCitation)
+		}
+
+		t := b.terms[tok.Key]
+		if t == nil {
+			t = &termPostings{}
+			b.terms[tok.Key] = t
+		}
+		t.add(doc, tok.Pos, line)
+	}
+	finish()
+	return nil
+}
+
+// WriteTo writes the index file.
+func (b *IndexBuilder) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	cw.Write([]byte(indexMagic))
+
+	keys := make([]string, 0, len(b.terms))
+	for k := range b.terms {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	postOffs := make([]int64, len(keys))
+	for i, k := range keys {
+		postOffs[i] = cw.n
+		cw.Write(b.terms[k].buf)
+	}
+
+	linesBase := cw.n
+	cw.Write(b.lines.Bytes())
+
+	docsOff := cw.n
+	var buf []byte
+	buf = binary.AppendUvarint(buf, uint64(len(b.docs)))
+	for _, d := range b.docs {
+		buf = appendString(buf, d.textID)
+		buf = appendString(buf, d.workID)
+		buf = binary.AppendUvarint(buf, uint64(linesBase+d.linesOff))
+		buf = binary.AppendUvarint(buf, uint64(d.linesLen))
+	}
+	cw.Write(buf)
+
+	termsOff := cw.n
+	var sparse []byte
+	nSparse := 0
+	for i, k := range keys {
+		if i%indexSparseStep == 0 {
+			sparse = appendString(sparse, k)
+			sparse = binary.AppendUvarint(sparse, uint64(cw.n))
+			nSparse++
+		}
+		t := b.terms[k]
+		buf = buf[:0]
+		buf = appendString(buf, k)
+		buf = binary.AppendUvarint(buf, uint64(postOffs[i]))
+		buf = binary.AppendUvarint(buf, uint64(len(t.buf)))
+		buf = binary.AppendUvarint(buf, uint64(t.n))
+		cw.Write(buf)
+	}
+
+	sparseOff := cw.n
+	cw.Write(binary.AppendUvarint(nil, uint64(nSparse)))
+	cw.Write(sparse)
+
+	var footer [indexFooterSize]byte
+	binary.LittleEndian.PutUint64(footer[0:], uint64(docsOff))
+	binary.LittleEndian.PutUint64(footer[8:], uint64(termsOff))
+	binary.LittleEndian.PutUint64(footer[16:], uint64(sparseOff))
+	copy(footer[24:], indexMagic)
+	cw.Write(footer[:])
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.(*bufio.Writer).Flush()
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+type sparseTerm struct {
+	key string
+	off int64
+}
+
+// Index answers word and phrase queries from an index file written by
+// IndexBuilder.
+type Index struct {
+	r         io.ReaderAt
+	docs      []indexDoc
+	sparse    []sparseTerm
+	sparseOff int64
+}
+
+func OpenIndex(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ix, err := NewIndex(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return ix, nil
+}
+
+func NewIndex(r io.ReaderAt, size int64) (*Index, error) {
+	if size < int64(len(indexMagic))+indexFooterSize {
+		return nil, errors.New("index file too short")
+	}
+	var footer [indexFooterSize]byte
+	if _, err := r.ReadAt(footer[:], size-indexFooterSize); err != nil {
+		return nil, err
+	}
+	if string(footer[24:]) != indexMagic {
+		return nil, errors.New("not a lyceum corpus index")
+	}
+
+	docsOff := int64(binary.LittleEndian.Uint64(footer[0:]))
+	termsOff := int64(binary.LittleEndian.Uint64(footer[8:]))
+	ix := &Index{
+		r:         r,
+		sparseOff: int64(binary.LittleEndian.Uint64(footer[16:])),
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(r, docsOff, termsOff-docsOff))
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("index docs: %v", err)
+	}
+	ix.docs = make([]indexDoc, n)
+	for i := range ix.docs {
+		d := &ix.docs[i]
+		if d.textID, err = readString(br); err != nil {
+			return nil, fmt.Errorf("index docs: %v", err)
+		}
+		if d.workID, err = readString(br); err != nil {
+			return nil, fmt.Errorf("index docs: %v", err)
+		}
+		off, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("index docs: %v", err)
+		}
+		l, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("index docs: %v", err)
+		}
+		d.linesOff, d.linesLen = int64(off), int64(l)
+	}
+
+	br = bufio.NewReader(io.NewSectionReader(r, ix.sparseOff, size-indexFooterSize-ix.sparseOff))
+	n, err = binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("index terms: %v", err)
+	}
+	ix.sparse = make([]sparseTerm, n)
+	for i := range ix.sparse {
+		key, err := readString(br)
+		if err != nil {
+			return nil, fmt.Errorf("index terms: %v", err)
+		}
+		off, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("index terms: %v", err)
+		}
+		ix.sparse[i] = sparseTerm{key, int64(off)}
+	}
+	return ix, nil
+}
+
+func (ix *Index) Close() error {
+	if c, ok := ix.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type rawPosting struct {
+	doc, pos, line int
+}
+
+// postings returns the raw postings of a key, or nil if it is not indexed.
+func (ix *Index) postings(key string) ([]rawPosting, error) {
+	i := sort.Search(len(ix.sparse), func(i int) bool { return ix.sparse[i].key > key }) - 1
+	if i < 0 {
+		return nil, nil
+	}
+	end := ix.sparseOff
+	if i+1 < len(ix.sparse) {
+		end = ix.sparse[i+1].off
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(ix.r, ix.sparse[i].off, end-ix.sparse[i].off))
+	for {
+		k, err := readString(br)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		off, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		length, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		if k < key {
+			continue
+		}
+		if k > key {
+			return nil, nil
+		}
+
+		data := make([]byte, length)
+		if _, err := ix.r.ReadAt(data, int64(off)); err != nil {
+			return nil, err
+		}
+		return decodePostings(data, int(n))
+	}
+}
+
+func decodePostings(data []byte, n int) ([]rawPosting, error) {
+	res := make([]rawPosting, 0, n)
+	r := bytes.NewReader(data)
+	var cur rawPosting
+	for i := 0; i < n; i++ {
+		dd, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		dp, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		dl, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if dd > 0 {
+			cur = rawPosting{doc: cur.doc + int(dd)}
+		}
+		cur.pos += int(dp)
+		cur.line += int(dl)
+		res = append(res, cur)
+	}
+	return res, nil
+}
+
+// Search looks up a word or phrase typed in Greek or Beta Code. For a
+// phrase it returns the postings of its first word.
+func (ix *Index) Search(query string) ([]Posting, error) {
+	keys := QueryKeys(query)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	return ix.Phrase(keys)
+}
+
+// Lookup returns the postings of a single normalized word (see WordKey).
+func (ix *Index) Lookup(key string) ([]Posting, error) {
+	return ix.Phrase([]string{key})
+}
+
+// Phrase returns the postings of keys[0] wherever keys occur as
+// consecutive tokens of one work. No keys match nothing.
+func (ix *Index) Phrase(keys []string) ([]Posting, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	first, err := ix.postings(keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(keys) && len(first) > 0; i++ {
+		next, err := ix.postings(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		at := make(map[[2]int]bool, len(next))
+		for _, rp := range next {
+			at[[2]int{rp.doc, rp.pos}] = true
+		}
+		kept := first[:0]
+		for _, rp := range first {
+			if at[[2]int{rp.doc, rp.pos + i}] {
+				kept = append(kept, rp)
+			}
+		}
+		first = kept
+	}
+
+	return ix.resolve(first)
+}
+
+type indexLine struct {
+	block    int
+	citation string
+}
+
+func (ix *Index) resolve(raw []rawPosting) ([]Posting, error) {
+	tables := make(map[int][]indexLine)
+	res := make([]Posting, 0, len(raw))
+
+	for _, rp := range raw {
+		if rp.doc >= len(ix.docs) {
+			return nil, fmt.Errorf("corrupt index: doc %d out of range", rp.doc)
+		}
+		d := ix.docs[rp.doc]
+
+		lines, ok := tables[rp.doc]
+		if !ok {
+			var err error
+			if lines, err = ix.lineTable(d); err != nil {
+				return nil, err
+			}
+			tables[rp.doc] = lines
+		}
+
+		p := Posting{TextID: d.textID, WorkID: d.workID, Line: rp.line, Pos: rp.pos}
+		if rp.line < len(lines) {
+			p.Block = lines[rp.line].block
+			p.Citation = lines[rp.line].citation
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func (ix *Index) lineTable(d indexDoc) ([]indexLine, error) {
+	br := bufio.NewReader(io.NewSectionReader(ix.r, d.linesOff, d.linesLen))
+	var lines []indexLine
+	for {
+		blk, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cit, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, indexLine{int(blk), cit})
+	}
+}
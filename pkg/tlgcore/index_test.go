@@ -0,0 +1,172 @@
+package tlgcore
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// testText builds a one-block TLG text file for author 9999 holding the
+// given works, each a list of Beta Code lines cited by line number.
+func testText(works ...[]string) []byte {
+	var b []byte
+	for w, lines := range works {
+		for n, line := range lines {
+			if n == 0 {
+				b = append(b, 0xef, 0x80)
+				b = append(b, testID("9999")...)
+				b = append(b, 0xef, 0x81)
+				b = append(b, testID(string(rune('1'+w)))...)
+				b = append(b, 0x88, 0x81)
+			} else {
+				b = append(b, 0x80)
+			}
+			b = append(b, line...)
+		}
+	}
+	b = append(b, 0xf0)
+	return append(b, make([]byte, BlockSize-len(b))...)
+}
+
+func buildTestIndex(t *testing.T, works ...[]string) *Index {
+	t.Helper()
+	corpus := NewCorpus(fstest.MapFS{
+		"tlg9999.txt": &fstest.MapFile{Data: testText(works...)},
+	})
+	b := NewIndexBuilder()
+	if err := b.AddText(corpus, "tlg9999"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+	ix, err := NewIndex(bytes.NewReader(buf.Bytes()), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ix
+}
+
+type testPosting struct {
+	work     string
+	citation string
+	pos      int
+}
+
+func postingsOf(ps []Posting) []testPosting {
+	var out []testPosting
+	for _, p := range ps {
+		out = append(out, testPosting{p.WorkID, p.Citation, p.Pos})
+	}
+	return out
+}
+
+var testWorks = [][]string{
+	{
+		"MH=NIN A)/EIDE QEA\\ *PHLHI+A/DEW A)XILH=OS",
+		"OU)LOME/NHN, H(\\ MURI/' *)AXAIOI=S A)/LGE' E)/QHKE,",
+		"POLLA\\S D' I)FQI/MOUS YUXA\\S *)/AI+DI PRO/I+AYEN",
+	},
+	{
+		"E)N A)RXH=| H)=N O( LO/GOS, KAI\\ O( LO/GOS H)=N",
+		"PRO\\S TO\\N QEO/N, KAI\\ QEO\\S H)=N O( LO/GOS.",
+	},
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	ix := buildTestIndex(t, testWorks...)
+	defer ix.Close()
+
+	tests := []struct {
+		key  string
+		want []testPosting
+	}{
+		{"mhnin", []testPosting{{"1", "1", 0}}},
+		{"axaiois", []testPosting{{"1", "2", 8}}},
+		{"yuxas", []testPosting{{"1", "3", 14}}},
+		{"logos", []testPosting{{"2", "1", 4}, {"2", "1", 7}, {"2", "2", 16}}},
+		{"hn", []testPosting{{"2", "1", 2}, {"2", "1", 8}, {"2", "2", 14}}},
+		{"qeos", []testPosting{{"2", "2", 13}}},
+		{"qeon", []testPosting{{"2", "2", 11}}},
+		{"absent", nil},
+	}
+	for _, tt := range tests {
+		got, err := ix.Lookup(tt.key)
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", tt.key, err)
+			continue
+		}
+		if !slices.Equal(postingsOf(got), tt.want) {
+			t.Errorf("Lookup(%q) = %v, want %v", tt.key, postingsOf(got), tt.want)
+		}
+		for _, p := range got {
+			if p.TextID != "tlg9999" || p.Block != 0 {
+				t.Errorf("Lookup(%q): posting in %s block %d, want tlg9999 block 0", tt.key, p.TextID, p.Block)
+			}
+		}
+	}
+}
+
+func TestPhrase(t *testing.T) {
+	ix := buildTestIndex(t, testWorks...)
+	defer ix.Close()
+
+	tests := []struct {
+		keys []string
+		want []testPosting
+	}{
+		{nil, nil},
+		{[]string{"o", "logos"}, []testPosting{{"2", "1", 3}, {"2", "1", 6}, {"2", "2", 15}}},
+		{[]string{"o", "logos", "hn"}, []testPosting{{"2", "1", 6}}},
+		{[]string{"hn", "o", "logos"}, []testPosting{{"2", "1", 2}, {"2", "2", 14}}},
+		// Adjacent across a line end.
+		{[]string{"hn", "pros"}, []testPosting{{"2", "1", 8}}},
+		// Both words occur, but not next to each other.
+		{[]string{"logos", "o"}, nil},
+		{[]string{"qeos", "o"}, nil},
+		// The last word of one work and the first of the next are not adjacent.
+		{[]string{"proiayen", "en"}, nil},
+		{[]string{"logos", "absent"}, nil},
+	}
+	for _, tt := range tests {
+		got, err := ix.Phrase(tt.keys)
+		if err != nil {
+			t.Errorf("Phrase(%q): %v", tt.keys, err)
+			continue
+		}
+		if !slices.Equal(postingsOf(got), tt.want) {
+			t.Errorf("Phrase(%q) = %v, want %v", tt.keys, postingsOf(got), tt.want)
+		}
+	}
+}
+
+func TestIndexTruncated(t *testing.T) {
+	corpus := NewCorpus(fstest.MapFS{
+		"tlg9999.txt": &fstest.MapFile{Data: testText(testWorks...)},
+	})
+	b := NewIndexBuilder()
+	if err := b.AddText(corpus, "tlg9999"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	for _, n := range []int{0, len(indexMagic), len(data) / 2, len(data) - 1} {
+		ix, err := NewIndex(bytes.NewReader(data[:n]), int64(n))
+		if err == nil {
+			_, err = ix.Lookup("logos")
+		}
+		if err == nil {
+			t.Errorf("index cut to %d of %d bytes: no error", n, len(data))
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package tlgcore
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// KWIC is one keyword-in-context row. Left and Right hold up to the
+// builder's width in runes of the text around the keyword, taken across
+// line boundaries of the same work.
+type KWIC struct {
+	Left    string
+	Keyword string
+	Right   string
+	Tokens  []Token
+}
+
+type kwicLine struct {
+	line *Line
+	off  int
+}
+
+type kwicHit struct {
+	start, end int
+	toks       []Token
+}
+
+// KWICBuilder turns a stream of tokens into KWIC rows. Feed every token of
+// the stream to Add and mark matches with Hit; a row becomes Ready once
+// enough text follows its keyword, or when the work changes or Flush is
+// called.
+type KWICBuilder struct {
+	Width int
+
+	work    string
+	buf     []byte // decoded text of the current work, lines joined by a space
+	base    int    // stream offset of buf[0]
+	lines   []kwicLine
+	pending []kwicHit
+	ready   []KWIC
+}
+
+func NewKWICBuilder(width int) *KWICBuilder {
+	return &KWICBuilder{Width: width}
+}
+
+// Add appends the text of the token's line, and of the line it continues
+// on if it is hyphenated, to the context stream.
+func (b *KWICBuilder) Add(tok Token) {
+	if tok.Line.WorkID != b.work {
+		b.Flush()
+		b.work = tok.Line.WorkID
+		b.buf = b.buf[:0]
+		b.base = 0
+		b.lines = b.lines[:0]
+	}
+	b.addLine(tok.Line)
+	if tok.Next != nil {
+		b.addLine(tok.Next)
+	}
+}
+
+func (b *KWICBuilder) addLine(line *Line) {
+	if n := len(b.lines); n > 0 && b.lines[n-1].line == line {
+		return
+	}
+
+	if len(b.buf) > 0 {
+		b.buf = append(b.buf, ' ')
+	}
+	b.lines = append(b.lines, kwicLine{line, b.base + len(b.buf)})
+	b.buf = append(b.buf, line.Text...)
+	b.release(false)
+	b.trim()
+}
+
+// Hit records toks, a run of tokens already passed to Add, as a keyword.
+func (b *KWICBuilder) Hit(toks []Token) {
+	first, last := toks[0], toks[len(toks)-1]
+	lastLine, lastEnd := last.Line, last.End
+	if last.Next != nil {
+		lastLine, lastEnd = last.Next, last.NextEnd
+	}
+	start, ok1 := b.offset(first.Line)
+	end, ok2 := b.offset(lastLine)
+	if !ok1 || !ok2 {
+		return
+	}
+	b.pending = append(b.pending, kwicHit{start + first.Start, end + lastEnd, toks})
+	b.release(false)
+}
+
+// Ready returns the rows completed so far and forgets them.
+func (b *KWICBuilder) Ready() []KWIC {
+	r := b.ready
+	b.ready = nil
+	return r
+}
+
+// Flush completes every pending row with whatever right context exists.
+func (b *KWICBuilder) Flush() {
+	b.release(true)
+}
+
+func (b *KWICBuilder) offset(line *Line) (int, bool) {
+	for i := len(b.lines) - 1; i >= 0; i-- {
+		if b.lines[i].line == line {
+			return b.lines[i].off, true
+		}
+	}
+	return 0, false
+}
+
+func (b *KWICBuilder) release(all bool) {
+	n := 0
+	for _, h := range b.pending {
+		right := b.buf[h.end-b.base:]
+		right = right[:min(len(right), 2*utf8.UTFMax*b.Width)]
+		if !all && utf8.RuneCount(right) <= b.Width {
+			b.pending[n] = h
+			n++
+			continue
+		}
+		left := max(h.start-b.base-2*utf8.UTFMax*b.Width, 0)
+		for left > 0 && !utf8.RuneStart(b.buf[left]) {
+			left++
+		}
+		b.ready = append(b.ready, KWIC{
+			Left:    lastRunes(string(b.buf[left:h.start-b.base]), b.Width),
+			Keyword: string(b.buf[h.start-b.base : h.end-b.base]),
+			Right:   firstRunes(string(right), b.Width),
+			Tokens:  h.toks,
+		})
+	}
+	b.pending = b.pending[:n]
+}
+
+// trim drops text that no pending or future row can still need. The last
+// three lines are always kept so that Hit can find a phrase ending on the
+// newest one.
+func (b *KWICBuilder) trim() {
+	n := len(b.lines)
+	if n < 3 {
+		return
+	}
+	from := b.lines[n-3].off
+	if len(b.pending) > 0 {
+		from = min(from, b.pending[0].start)
+	}
+	cut := from - b.base - utf8.UTFMax*b.Width
+	if cut < 4096 {
+		return
+	}
+	for cut < len(b.buf) && !utf8.RuneStart(b.buf[cut]) {
+		cut++
+	}
+	b.buf = append(b.buf[:0], b.buf[cut:]...)
+	b.base += cut
+	k := 0
+	for _, l := range b.lines {
+		if l.off >= b.base {
+			b.lines[k] = l
+			k++
+		}
+	}
+	b.lines = b.lines[:k]
+}
+
+func lastRunes(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if c := utf8.RuneCountInString(s); c > n {
+		r := []rune(s)
+		s = string(r[c-n:])
+	}
+	return s
+}
+
+func firstRunes(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > n {
+		s = string([]rune(s)[:n])
+	}
+	return s
+}
+
+// KWICAt rebuilds the row of one indexed occurrence of the phrase keys: the
+// nth match (counting from 0) on the line with the given citation. Only
+// the blocks around block are read.
+func (p *Parser) KWICAt(workID string, block int, citation string, keys []string, nth, width int) (KWIC, error) {
+	b := NewKWICBuilder(width)
+	var window []Token
+	start := max(block-1, 0)
+
+	for tok, err := range p.TokensFrom(workID, start, block-start+2) {
+		if err != nil {
+			return KWIC{}, err
+		}
+		b.Add(tok)
+		window = append(window, tok)
+		if len(window) > len(keys) {
+			window = window[1:]
+		}
+		if len(window) == len(keys) && window[0].Line.FormattedCitation == citation && matchKeys(window, keys) {
+			if nth == 0 {
+				b.Hit(append([]Token(nil), window...))
+			}
+			nth--
+		}
+		if r := b.Ready(); len(r) > 0 {
+			return r[0], nil
+		}
+	}
+	b.Flush()
+	if r := b.Ready(); len(r) > 0 {
+		return r[0], nil
+	}
+	return KWIC{}, fmt.Errorf("occurrence at %s of work %s not found near block %d", citation, workID, block)
+}
+
+func matchKeys(toks []Token, keys []string) bool {
+	for i, k := range keys {
+		if toks[i].Key != k {
+			return false
+		}
+	}
+	return true
+}
+
+// Concordance is a KWIC row with its full citation.
+type Concordance struct {
+	AuthorID string
+	Author   string
+	WorkID   string
+	Title    string
+	Citation string
+	KWIC
+}
+
+// SortConcordance orders rows by "left" context (nearest word first),
+// "right" context, "author" or "citation". Words compare by WordKey so
+// accents and case do not split the sort.
+func SortConcordance(rows []Concordance, by string) error {
+	var cmp func(a, b *Concordance) int
+	switch by {
+	case "left":
+		cmp = func(a, b *Concordance) int {
+			return slices.Compare(reversedKeys(a.Left), reversedKeys(b.Left))
+		}
+	case "right":
+		cmp = func(a, b *Concordance) int {
+			return slices.Compare(contextKeys(a.Right), contextKeys(b.Right))
+		}
+	case "author":
+		cmp = func(a, b *Concordance) int {
+			if c := strings.Compare(a.Author, b.Author); c != 0 {
+				return c
+			}
+			return compareLocation(a, b)
+		}
+	case "citation":
+		cmp = compareLocation
+	default:
+		return fmt.Errorf("unknown sort %q (want left, right, author or citation)", by)
+	}
+
+	slices.SortStableFunc(rows, func(a, b Concordance) int {
+		if c := cmp(&a, &b); c != 0 {
+			return c
+		}
+		return strings.Compare(WordKey(a.Keyword), WordKey(b.Keyword))
+	})
+	return nil
+}
+
+func compareLocation(a, b *Concordance) int {
+	if c := strings.Compare(a.AuthorID, b.AuthorID); c != 0 {
+		return c
+	}
+	if c := compareCitationPart(a.WorkID, b.WorkID); c != 0 {
+		return c
+	}
+	return slices.CompareFunc(strings.Split(a.Citation, "."), strings.Split(b.Citation, "."), compareCitationPart)
+}
+
+func contextKeys(s string) []string {
+	var keys []string
+	for _, w := range Words(s) {
+		if k := WordKey(w); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func reversedKeys(s string) []string {
+	keys := contextKeys(s)
+	slices.Reverse(keys)
+	return keys
+}
+
+// WriteConcordance writes rows as aligned "text" or as "tsv" or "csv"
+// with a header line.
+func WriteConcordance(w io.Writer, rows []Concordance, format string, width int) error {
+	switch format {
+	case "text":
+		for _, r := range rows {
+			pad := max(width-utf8.RuneCountInString(r.Left), 0)
+			_, err := fmt.Fprintf(w, "%s.%s %-10s %s%s *%s* %s\n",
+				r.AuthorID, r.WorkID, r.Citation, strings.Repeat(" ", pad), r.Left, r.Keyword, r.Right)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "tsv", "csv":
+		cw := csv.NewWriter(w)
+		if format == "tsv" {
+			cw.Comma = '\t'
+		}
+		cw.Write([]string{"author_id", "author", "work_id", "title", "citation", "left", "keyword", "right"})
+		for _, r := range rows {
+			cw.Write([]string{r.AuthorID, r.Author, r.WorkID, r.Title, r.Citation, r.Left, r.Keyword, r.Right})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q (want text, tsv or csv)", format)
+}
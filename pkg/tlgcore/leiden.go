@@ -0,0 +1,130 @@
+package tlgcore
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// underdotMark stands in for a ? read as an underdot until the text is
+// decoded.
+const underdotMark = '\uE001'
+
+var leidenBrackets = map[Bracket][2]string{
+	BracketSupplement: {"[", "]"},
+	BracketLacuna:     {"[", "]"},
+	BracketParen:      {"(", ")"},
+	BracketAddition:   {"⟨", "⟩"},
+	BracketDeletion:   {"{", "}"},
+	BracketErasure:    {"⟦", "⟧"},
+	BracketUncertain:  {"⸢", "⸣"},
+}
+
+// Leiden renders the tree in the Leiden conventions of papyrological and
+// epigraphical editions, for the documents of PHI 7: a ? after a letter
+// is an underdot, lacunae show one dot per lost letter, [ca. 10] for an
+// estimate and [– – –] for an unknown extent, erasures are ⟦ ⟧, doubtful
+// text ⸢ ⸣, additions ⟨ ⟩ and deletions { }. Unknown commands are
+// dropped.
+func (t *BetaTree) Leiden() string {
+	var sb strings.Builder
+	leidenNodes(&sb, t.Nodes)
+	return sb.String()
+}
+
+func leidenNodes(sb *strings.Builder, nodes []*BetaNode) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case BetaText:
+			sb.WriteString(leidenText(n.Beta, n.Script))
+		case BetaSymbol, BetaLayout:
+			sb.WriteString(n.Text)
+		case BetaUnknown:
+		default:
+			open, close := n.Open, n.Close
+			if g, ok := leidenBrackets[n.Bracket]; ok && n.Kind == BetaBracket {
+				open, close = g[0], g[1]
+				if n.Continued {
+					open = ""
+				}
+				if n.Unclosed {
+					close = ""
+				}
+			}
+			sb.WriteString(open)
+			if n.Kind == BetaBracket && n.Bracket == BracketLacuna {
+				sb.WriteString(lacunaExtent(n.Children))
+			} else {
+				leidenNodes(sb, n.Children)
+			}
+			sb.WriteString(close)
+		}
+	}
+}
+
+// lacunaExtent writes the inside of a lacuna: its dots without spacing,
+// the estimate, or dashes when the number of letters lost is unknown.
+func lacunaExtent(nodes []*BetaNode) string {
+	beta := betaNodes(nodes)
+	if m := lacunaNumber.FindStringSubmatch(beta); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if strings.ContainsAny(m[0], "cC") {
+			return "ca. " + m[1]
+		}
+		return strings.Repeat(".", n)
+	}
+	if n := strings.Count(plainNodes(nodes), "."); n > 0 {
+		return strings.Repeat(".", n)
+	}
+	return "– – –"
+}
+
+var finalUnderdotSigma = regexp.MustCompile(`σ(\x{E001}(?:\s|[[:punct:],·]|$))`)
+
+// leidenText decodes Beta Code with ? after a letter, and after its
+// diacritics, as an underdot rather than a question mark.
+func leidenText(beta string, script Script) string {
+	dias := Diacritics
+	switch script {
+	case ScriptLatin:
+		dias = nil
+	case ScriptCoptic:
+		dias = copticDiacritics
+	}
+
+	runes := []rune(beta)
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != '?' || !afterLetter(out, dias) {
+			out = append(out, r)
+			continue
+		}
+		for i+1 < len(runes) && dias[runes[i+1]] != "" {
+			i++
+			out = append(out, runes[i])
+		}
+		out = append(out, underdotMark)
+	}
+
+	s := parseBetaCode(string(out), script)
+	s = finalUnderdotSigma.ReplaceAllString(s, "ς$1")
+	return strings.ReplaceAll(s, string(underdotMark), "\u0323")
+}
+
+// afterLetter reports whether the Beta Code in out ends with a letter,
+// its number (S1, H1) or its diacritics.
+func afterLetter(out []rune, dias map[rune]string) bool {
+	i := len(out) - 1
+	for i >= 0 && dias[out[i]] != "" {
+		i--
+	}
+	if i > 0 && out[i] >= '1' && out[i] <= '3' {
+		i--
+	}
+	if i < 0 {
+		return false
+	}
+	r := out[i] | 0x20
+	return r >= 'a' && r <= 'z'
+}
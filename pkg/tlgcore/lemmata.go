@@ -0,0 +1,99 @@
+package tlgcore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LemmaInfo is one line of diogenes' greek-lemmata.txt or
+// latin-lemmata.txt: a lemma and its inflected forms, each given as
+// "form analysis".
+type LemmaInfo struct {
+	Lemma string
+	Forms []string
+}
+
+// LemmaForm is one inflected form of a lemma with its analysis.
+type LemmaForm struct {
+	Form     string `json:"form"`
+	Analysis string `json:"analysis"`
+}
+
+func FindForms(filePath, targetLemma string) (*LemmaInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Split(line, "\t")
+
+		if len(parts) < 3 {
+			continue
+		}
+
+		lemma := strings.TrimSpace(parts[0])
+		if lemma == targetLemma {
+			allForms := parts[2:]
+			return &LemmaInfo{
+				Lemma: lemma,
+				Forms: allForms,
+			}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("lemma %s not found", targetLemma)
+}
+
+// Words returns the distinct inflected forms of the lemma without their
+// analyses, in Beta Code for Greek.
+func (li *LemmaInfo) Words() []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, f := range li.Forms {
+		fields := strings.Fields(f)
+		if len(fields) == 0 || seen[fields[0]] {
+			continue
+		}
+		seen[fields[0]] = true
+		words = append(words, fields[0])
+	}
+	return words
+}
+
+// Inflections splits the forms of the lemma into form and analysis.
+func (li *LemmaInfo) Inflections() []LemmaForm {
+	var forms []LemmaForm
+	for _, f := range li.Forms {
+		if f == "" {
+			continue
+		}
+		form, analysis, _ := strings.Cut(f, " ")
+		forms = append(forms, LemmaForm{Form: form, Analysis: strings.TrimSpace(analysis)})
+	}
+	return forms
+}
+
+// LemmaQuery converts a lemma typed in Greek or Beta Code into the Beta
+// Code spelling used by the lemmata files. Greek may be typed in any
+// OutputForm.
+func LemmaQuery(word string) string {
+	q := word
+	for _, r := range word {
+		if r > 127 {
+			q = ToBetaCode(NFC.Apply(word))
+			break
+		}
+	}
+	return NormalizeBetaCode(q)
+}
@@ -0,0 +1,205 @@
+package tlgcore
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Line is one citation line of a TLG/PHI text.
+type Line struct {
+	WorkID            string
+	Citation          map[string]string // level char -> value, e.g. "y": "2"
+	FormattedCitation string
+	RawBetaCode       string
+	Text              string
+	Block             int
+}
+
+// CitedLine is a Line as the commands write it in JSON: its citation both
+// formatted and split into the labeled levels of the work's schema.
+type CitedLine struct {
+	WorkID   string          `json:"work"`
+	Citation string          `json:"citation"`
+	Levels   []CitationLevel `json:"levels"`
+	Text     string          `json:"text"`
+}
+
+type CitationLevel struct {
+	Level string `json:"level"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Cited returns the line with its citation levels named after meta, which
+// may be nil.
+func (l Line) Cited(meta *WorkMetadata) CitedLine {
+	c := CitedLine{WorkID: l.WorkID, Citation: l.FormattedCitation, Levels: []CitationLevel{}, Text: strings.TrimSpace(l.Text)}
+	labels := make(map[string]string)
+	levels := []string{"w", "x", "y", "z"}
+	if meta != nil && len(meta.Citations) > 0 {
+		for _, def := range meta.Citations {
+			if _, ok := labels[def.LevelChar]; !ok {
+				labels[def.LevelChar] = def.Label
+			}
+		}
+		levels = citationLevels(meta)
+	}
+	for _, lv := range levels {
+		if v, ok := l.Citation[lv]; ok && v != "" {
+			c.Levels = append(c.Levels, CitationLevel{Level: lv, Label: labels[lv], Value: v})
+		}
+	}
+	return c
+}
+
+// Lines iterates over the lines of a work, or of every work in the file
+// when workID is empty. Lines that decode to blank text are skipped.
+func (p *Parser) Lines(workID string) iter.Seq2[Line, error] {
+	return p.lines(workID, p.workBlock(workID), 0)
+}
+
+// PassageLines returns the lines of a work whose citations fall between
+// from and to inclusive, e.g. from "2.34" to "2.46". Either bound may be
+// empty to run from the start or to the end of the work.
+func (p *Parser) PassageLines(workID, from, to string) ([]Line, error) {
+	var meta *WorkMetadata
+	if p.IDTData != nil {
+		meta = p.IDTData[workID]
+	}
+	if meta == nil {
+		return nil, fmt.Errorf("work ID %s not found in IDT", workID)
+	}
+
+	var fromCit, toCit []string
+	var err error
+	if from != "" {
+		if fromCit, err = ParseCitation(meta, from); err != nil {
+			return nil, fmt.Errorf("invalid -from citation: %v", err)
+		}
+	}
+	if to != "" {
+		if toCit, err = ParseCitation(meta, to); err != nil {
+			return nil, fmt.Errorf("invalid -to citation: %v", err)
+		}
+	}
+	if fromCit != nil && toCit != nil {
+		n := min(len(fromCit), len(toCit))
+		if compareCitation(fromCit[:n], toCit[:n]) > 0 {
+			return nil, fmt.Errorf("citation range %s-%s is reversed", from, to)
+		}
+	}
+
+	var result []Line
+	found := false
+
+	for line, err := range p.lines(workID, p.citationBlock(meta, fromCit), 0) {
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		cit := p.currentCitation()
+		if fromCit != nil && compareCitation(cit, fromCit) < 0 {
+			continue
+		}
+		if toCit != nil && compareCitation(cit, toCit) > 0 {
+			break
+		}
+		result = append(result, line)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("work ID %s not found", workID)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no lines of work %s between %q and %q (cited by %s)",
+			workID, from, to, citationLabels(meta))
+	}
+	return result, nil
+}
+
+// LineAt returns the line of a work with the given formatted citation,
+// reading only the block it lies in (see Posting).
+func (p *Parser) LineAt(workID string, block int, citation string) (Line, error) {
+	for line, err := range p.lines(workID, block, 1) {
+		if err != nil {
+			return Line{}, err
+		}
+		if line.FormattedCitation == citation {
+			return line, nil
+		}
+	}
+	return Line{}, fmt.Errorf("line %s of work %s not found in block %d", citation, workID, block)
+}
+
+// lines reads up to maxBlocks blocks (0 for all) from startBlock and
+// yields the lines of workID, or of every work when workID is empty.
+func (p *Parser) lines(workID string, startBlock, maxBlocks int) iter.Seq2[Line, error] {
+	return func(yield func(Line, error) bool) {
+		// Work IDs that are not numbers, as documents have, match only
+		// as they are.
+		targetInt, targetErr := strconv.Atoi(workID)
+		currentWork := ""
+		found := false
+
+		p.setWork(workID)
+
+		err := p.scanFrom(startBlock, maxBlocks, func(text string) bool {
+			currentID := p.getCurrentWorkID()
+
+			if workID != "" {
+				currentInt := 0
+				if val, err := strconv.Atoi(currentID); err == nil {
+					currentInt = val
+				}
+				if currentID != workID && (targetErr != nil || currentInt != targetInt) {
+					return !found
+				}
+				found = true
+			} else {
+				if currentID == "0" {
+					return true
+				}
+				if currentID != currentWork {
+					currentWork = currentID
+					p.setWork(currentID)
+				}
+			}
+
+			line := p.makeLine(currentID, text)
+			if strings.TrimSpace(line.Text) == "" {
+				return true
+			}
+			return yield(line, nil)
+		})
+		if err != nil {
+			yield(Line{}, err)
+		}
+	}
+}
+
+func (p *Parser) setWork(workID string) {
+	if p.IDTData == nil {
+		return
+	}
+	p.CurrentMeta = p.IDTData[workID]
+	p.analyzeCitationLevels()
+}
+
+func (p *Parser) makeLine(workID, raw string) Line {
+	cit := make(map[string]string)
+	for _, l := range p.citeLevels() {
+		if v := p.citationValue(l); v != "" {
+			cit[l] = v
+		}
+	}
+	return Line{
+		WorkID:            workID,
+		Citation:          cit,
+		FormattedCitation: p.formatCitation(),
+		RawBetaCode:       raw,
+		Text:              p.ProcessText(raw),
+		Block:             p.block,
+	}
+}
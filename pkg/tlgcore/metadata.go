@@ -2,21 +2,32 @@ package tlgcore
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
 type CitationDef struct {
-	LevelChar string // "v", "w", "x", "y", "z"
-	Label     string // e.g. "Book", "Line"
+	LevelChar string `json:"level"` // "v", "w", "x", "y", "z"
+	Label     string `json:"label"` // e.g. "Book", "Line"
 }
 
 type WorkMetadata struct {
-	ID        string
-	Title     string
-	Citations []CitationDef
+	ID        string        `json:"id"`
+	Title     string        `json:"title"`
+	Citations []CitationDef `json:"citations"`
+	Block     int           `json:"-"` // first 8 KB block of the work in the text file, -1 if unknown
+	Sections  []Section     `json:"-"` // starting blocks of citation sections within the work
+}
+
+// Section records where a run of the text file begins, as listed by the
+// type 3 (block) and type 8 (starting citation) records of the IDT.
+type Section struct {
+	Block  int
+	Levels map[string]IDState
 }
 
 func cleanString(s string) string {
@@ -26,7 +37,10 @@ func cleanString(s string) string {
 	return ToLatin(s)
 }
 
-func decodeSimpleASCII(b []byte) string {
+// decodeSimpleASCII decodes an ID string of letters and digits. The IDs of
+// documents, such as "P.Oxy. 1", keep their punctuation and spaces, as the
+// text files write them.
+func decodeSimpleASCII(b []byte, documents bool) string {
 	var sb strings.Builder
 	for i := 0; i < len(b); i++ {
 		if b[i] == 0xFF {
@@ -34,7 +48,8 @@ func decodeSimpleASCII(b []byte) string {
 		}
 		if b[i] >= 0x80 {
 			val := b[i] & 0x7F
-			if (val >= '0' && val <= '9') || (val >= 'A' && val <= 'Z') || (val >= 'a' && val <= 'z') {
+			if (val >= '0' && val <= '9') || (val >= 'A' && val <= 'Z') || (val >= 'a' && val <= 'z') ||
+				documents && val >= 0x20 && val < 0x7F {
 				sb.WriteByte(val)
 			}
 		}
@@ -47,11 +62,23 @@ func decodeSimpleASCII(b []byte) string {
 }
 
 func GetAuthorName(path, tlgID string) string {
-	var prefixID string
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return "Unknown"
 	}
+	return authorNameFromTable(data, tlgID)
+}
+
+func GetAuthorNameFS(fsys fs.FS, name, tlgID string) string {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "Unknown"
+	}
+	return authorNameFromTable(data, tlgID)
+}
+
+func authorNameFromTable(data []byte, tlgID string) string {
+	var prefixID string
 
 	if len(tlgID) >= 3 {
 		prefixID = strings.ToUpper(tlgID[:3])
@@ -69,18 +96,38 @@ func GetAuthorName(path, tlgID string) string {
 }
 
 func ReadIDT(path string) (map[string]*WorkMetadata, error) {
-	m := make(map[string]*WorkMetadata)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return parseIDT(data, IsDocumentFileName(filepath.Base(path))), nil
+}
+
+func ReadIDTFS(fsys fs.FS, name string) (map[string]*WorkMetadata, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return parseIDT(data, IsDocumentFileName(filepath.Base(name))), nil
+}
+
+// ParseIDT parses the IDT of a TLG or PHI 5 text file. ReadIDT and
+// ReadIDTFS also read the IDTs of PHI 7 documents, named by file name.
+func ParseIDT(data []byte) map[string]*WorkMetadata {
+	return parseIDT(data, false)
+}
 
+func parseIDT(data []byte, documents bool) map[string]*WorkMetadata {
+	m := make(map[string]*WorkMetadata)
 	pos := 0
 	var currentWork *WorkMetadata
 
 	lastWorkIDInt := 0
 	lastWorkIDStr := ""
 
+	sectionBlock := -1
+	citState := newLevelState()
+
 	consumeID := func() []byte {
 		start := pos
 		for pos < len(data) && data[pos] >= 0x80 {
@@ -110,6 +157,7 @@ func ReadIDT(path string) (map[string]*WorkMetadata, error) {
 			if pos+4 > len(data) {
 				break
 			}
+			block := readBlockNumber(data[pos+2:])
 			pos += 4
 			idBytes := consumeID()
 
@@ -117,7 +165,7 @@ func ReadIDT(path string) (map[string]*WorkMetadata, error) {
 				lastWorkIDInt++
 				lastWorkIDStr = ""
 			} else {
-				lastWorkIDInt, lastWorkIDStr = DecodeWorkID(lastWorkIDInt, lastWorkIDStr, idBytes)
+				lastWorkIDInt, lastWorkIDStr = DecodeWorkID(lastWorkIDInt, lastWorkIDStr, idBytes, documents)
 			}
 
 			idStr := lastWorkIDStr
@@ -132,12 +180,26 @@ func ReadIDT(path string) (map[string]*WorkMetadata, error) {
 				idStr = strconv.Itoa(lastWorkIDInt)
 			}
 
-			currentWork = &WorkMetadata{ID: idStr}
+			currentWork = &WorkMetadata{ID: idStr, Block: block}
 			m[idStr] = currentWork
+			sectionBlock = block
+			citState = newLevelState()
 
-		case 3:
+		case 3: // New Section
+			if pos+2 > len(data) {
+				break
+			}
+			sectionBlock = readBlockNumber(data[pos:])
 			pos += 2
-		case 8, 9, 10, 12, 13, 11:
+		case 8, 9: // Starting / Ending Citation
+			applyIDBytes(citState, consumeID())
+			if typ == 8 && currentWork != nil && sectionBlock >= 0 {
+				currentWork.Sections = append(currentWork.Sections, Section{
+					Block:  sectionBlock,
+					Levels: snapshotLevels(citState),
+				})
+			}
+		case 10, 12, 13, 11:
 			if typ == 11 {
 				pos += 2
 			}
@@ -156,7 +218,7 @@ func ReadIDT(path string) (map[string]*WorkMetadata, error) {
 					lastWorkIDInt++
 					lastWorkIDStr = ""
 					idStr := strconv.Itoa(lastWorkIDInt)
-					currentWork = &WorkMetadata{ID: idStr}
+					currentWork = &WorkMetadata{ID: idStr, Block: -1}
 					m[idStr] = currentWork
 				}
 
@@ -196,15 +258,48 @@ func ReadIDT(path string) (map[string]*WorkMetadata, error) {
 			continue
 		}
 	}
-	return m, nil
+	return m
+}
+
+func readBlockNumber(b []byte) int {
+	return int(b[0])<<8 | int(b[1])
+}
+
+func newLevelState() map[string]*IDState {
+	levels := make(map[string]*IDState)
+	for k := range levelRank {
+		levels[k] = &IDState{}
+	}
+	return levels
+}
+
+// applyIDBytes updates a citation state with ID bytes in the same encoding
+// the text files use.
+func applyIDBytes(levels map[string]*IDState, b []byte) {
+	p := &Parser{Levels: levels, Buffer: b}
+	for p.Pos < len(p.Buffer) {
+		if p.parseIDByte() {
+			break
+		}
+	}
+}
+
+func snapshotLevels(levels map[string]*IDState) map[string]IDState {
+	snap := make(map[string]IDState)
+	for k, st := range levels {
+		if st.Active {
+			snap[k] = *st
+		}
+	}
+	return snap
 }
 
-func DecodeWorkID(prevInt int, prevStr string, b []byte) (int, string) {
+func DecodeWorkID(prevInt int, prevStr string, b []byte, documents bool) (int, string) {
 	if len(b) == 0 {
 		return prevInt, prevStr
 	}
 	if len(b) >= 2 && b[0] == 0xEF && b[1] == 0x81 {
-		res := decodeSimpleASCII(b[2:])
+		res := decodeSimpleASCII(b[2:], documents)
 		if i, err := strconv.Atoi(res); err == nil {
 			return i, ""
 		}
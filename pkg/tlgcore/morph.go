@@ -0,0 +1,209 @@
+package tlgcore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MorphResult is one analysis of a form in diogenes' greek-analyses.txt
+// or latin-analyses.txt.
+type MorphResult struct {
+	Form       string `json:"form"`
+	Lemma      string `json:"lemma"`
+	ShortDef   string `json:"short_def"`
+	Morphology string `json:"morphology"`
+}
+
+// Greek returns the result with form and lemma decoded from Beta Code.
+func (m MorphResult) Greek() MorphResult {
+	m.Form = ToGreek(m.Form)
+	m.Lemma = ToGreek(m.Lemma)
+	return m
+}
+
+var (
+	analysisRE  = regexp.MustCompile(`\{[^ ]+ \d+ (?:[^,]+,)?(?P<lemma>[^ ]+)(?P<content>.*?)\}`)
+	analysisSep = regexp.MustCompile(`\s{2,}`)
+)
+
+// LoadIndex reads an analyses .idt file, which maps the first letters of
+// forms to offsets in the analyses file.
+func LoadIndex(idtPath string) (map[string]int64, []string, error) {
+	index := make(map[string]int64)
+	var keys []string
+	file, err := os.Open(idtPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	re := regexp.MustCompile(`'(.+?)' => (\d+)`)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		matches := re.FindStringSubmatch(scanner.Text())
+		if len(matches) == 3 {
+			offset, _ := strconv.ParseInt(matches[2], 10, 64)
+			key := matches[1]
+			index[key] = offset
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return index, keys, nil
+}
+
+// FindLemmaIndexed reads the analyses of searchForm, scanning the analyses
+// file from offset (see LoadIndex).
+func FindLemmaIndexed(filePath string, offset int64, searchForm string) ([]MorphResult, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	file.Seek(offset, 0)
+	scanner := bufio.NewScanner(file)
+	buf := make([]byte, 1024*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		currentWord := strings.TrimPrefix(fields[0], "!")
+
+		if strings.EqualFold(currentWord, searchForm) {
+			return parseAnalyses(line, searchForm), nil
+		}
+		if len(currentWord) > 0 && currentWord[0] > searchForm[0] {
+			break
+		}
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+// AnalyzeWord looks up a word typed in Greek (in any OutputForm) or Beta
+// Code in the analyses file, using the index from LoadIndex. A capitalized
+// form that is not found is tried again in lower case.
+func AnalyzeWord(analysesPath string, index map[string]int64, keys []string, word string) ([]MorphResult, error) {
+	searchWord := word
+	for _, r := range word {
+		if r > 127 {
+			searchWord = ToBetaCode(NFC.Apply(word))
+			break
+		}
+	}
+	searchWord = NormalizeBetaCode(searchWord)
+	if searchWord == "" {
+		return nil, fmt.Errorf("not found")
+	}
+
+	performSearch := func(query string) ([]MorphResult, error) {
+		idx := sort.SearchStrings(keys, query)
+		if idx > 0 {
+			idx -= 1
+		}
+
+		var res []MorphResult
+		var e error
+		for i := range 3 {
+			if idx-i < 0 {
+				break
+			}
+			res, e = FindLemmaIndexed(analysesPath, index[keys[idx-i]], query)
+			if e == nil {
+				return res, nil
+			}
+		}
+		return nil, fmt.Errorf("not found")
+	}
+
+	results, err := performSearch(searchWord)
+	if err != nil && strings.Contains(searchWord, "*") {
+		results, err = performSearch(BetaToLower(searchWord))
+	}
+	return results, err
+}
+
+func parseAnalyses(line, form string) []MorphResult {
+	var results []MorphResult
+	for _, match := range analysisRE.FindAllStringSubmatch(line, -1) {
+		parts := analysisSep.Split(strings.TrimSpace(match[2]), -1)
+
+		resDef := "---"
+		resMorph := ""
+		if len(parts) >= 2 {
+			resDef = strings.TrimSpace(parts[0])
+			resMorph = strings.TrimSpace(parts[1])
+		} else if len(parts) == 1 {
+			resMorph = strings.TrimSpace(parts[0])
+		}
+
+		results = append(results, MorphResult{
+			Form:       form,
+			Lemma:      strings.TrimSpace(match[1]),
+			ShortDef:   resDef,
+			Morphology: resMorph,
+		})
+	}
+	return results
+}
+
+// MorphKey is the spelling under which a Beta Code form is looked up in
+// the analyses files: lower case, with grave accents written as acute.
+func MorphKey(beta string) string {
+	return BetaToLower(NormalizeBetaCode(strings.TrimPrefix(beta, "!")))
+}
+
+// FormKey is MorphKey for a decoded word. Latin words are only lowered.
+func FormKey(word string, isLatin bool) string {
+	if isLatin {
+		return strings.ToLower(word)
+	}
+	return MorphKey(ToBetaCode(word))
+}
+
+// FormLemmas reads the whole analyses file once and returns the distinct
+// lemmata of every form in forms, keyed by FormKey. Forms without an
+// analysis are left out.
+func FormLemmas(filePath string, forms map[string]bool) (map[string][]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	lemmas := make(map[string][]string)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		key := MorphKey(fields[0])
+		if !forms[key] {
+			continue
+		}
+		for _, r := range parseAnalyses(line, key) {
+			fields := strings.Fields(r.Lemma)
+			if len(fields) == 0 {
+				continue
+			}
+			if !slices.Contains(lemmas[key], fields[0]) {
+				lemmas[key] = append(lemmas[key], fields[0])
+			}
+		}
+	}
+	return lemmas, scanner.Err()
+}
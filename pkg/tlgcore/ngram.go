@@ -0,0 +1,112 @@
+package tlgcore
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NGram is a run of N words, counted by their strict keys (see StrictKey),
+// with the first few places it occurs.
+type NGram struct {
+	Keys     []string
+	Count    int
+	Examples []NGramExample
+}
+
+// NGramExample is one occurrence of an n-gram. Text is the words as they
+// stand in the text.
+type NGramExample struct {
+	TextID   string
+	WorkID   string
+	Citation string // citation of the first word
+	Text     string
+}
+
+// NGramCounter counts the n-grams of a token stream. N-grams do not cross
+// work boundaries. Every distinct n-gram is held in memory, so counting a
+// large corpus needs room for all of them.
+type NGramCounter struct {
+	N           int
+	MaxExamples int
+
+	grams  map[string]*NGram
+	textID string
+	window []Token
+}
+
+func NewNGramCounter(n, maxExamples int) *NGramCounter {
+	return &NGramCounter{N: n, MaxExamples: maxExamples, grams: make(map[string]*NGram)}
+}
+
+// Add counts the n-gram ending with tok, a token of the text file textID.
+func (c *NGramCounter) Add(textID string, tok Token) {
+	if textID != c.textID || len(c.window) > 0 && c.window[0].Line.WorkID != tok.Line.WorkID {
+		c.textID = textID
+		c.window = c.window[:0]
+	}
+	c.window = append(c.window, tok)
+	if len(c.window) > c.N {
+		c.window = c.window[1:]
+	}
+	if len(c.window) < c.N {
+		return
+	}
+
+	keys := make([]string, c.N)
+	for i, t := range c.window {
+		keys[i] = StrictKey(t.Text)
+	}
+	id := strings.Join(keys, " ")
+	g, ok := c.grams[id]
+	if !ok {
+		g = &NGram{Keys: keys}
+		c.grams[id] = g
+	}
+	g.Count++
+
+	if len(g.Examples) < c.MaxExamples {
+		words := make([]string, c.N)
+		for i, t := range c.window {
+			words[i] = t.Text
+		}
+		first := c.window[0].Line
+		g.Examples = append(g.Examples, NGramExample{
+			TextID:   textID,
+			WorkID:   first.WorkID,
+			Citation: first.FormattedCitation,
+			Text:     strings.Join(words, " "),
+		})
+	}
+}
+
+// StrictKey is the NormalizeStrict form of a decoded word. A Greek word is
+// encoded as Beta Code first, so "Ἀχαιοῖς" gives "axaiois" as A)XAIOI=S
+// does; other words are taken as they stand. Unlike WordKey it keeps
+// elision marks, so μυρί’ ("muri'") is not counted as μυρί ("muri").
+func StrictKey(w string) string {
+	if r, _ := utf8.DecodeRuneInString(w); unicode.Is(unicode.Greek, r) {
+		w = EncodeBetaCode(strings.ToLower(w))
+	}
+	return NormalizeStrict(w)
+}
+
+// Top returns the n-grams seen at least minCount times, most frequent
+// first.
+func (c *NGramCounter) Top(minCount int) []NGram {
+	var result []NGram
+	for _, g := range c.grams {
+		if g.Count >= minCount {
+			result = append(result, *g)
+		}
+	}
+	slices.SortFunc(result, func(a, b NGram) int {
+		if d := cmp.Compare(b.Count, a.Count); d != 0 {
+			return d
+		}
+		return slices.Compare(a.Keys, b.Keys)
+	})
+	return result
+}
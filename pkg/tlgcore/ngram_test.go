@@ -0,0 +1,61 @@
+package tlgcore
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStrictKey(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"Ἀχαιοῖς", "axaiois"},
+		{"ἀχαιοὶ", "axaioi"},
+		{"ΟΔΥΣΣΕΥΣ", "odusseus"},
+		{"ϲοφία", "sofia"},
+		{"Πηληϊάδεω", "phlhiadew"},
+		{"ᾠδῇ", "wdh"},
+		{"μυρί’", "muri'"},
+		{"Arma", "arma"},
+		{"ⲁⲛⲟⲕ", "ⲁⲛⲟⲕ"},
+	}
+	for _, tt := range tests {
+		if got := StrictKey(tt.word); got != tt.want {
+			t.Errorf("StrictKey(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestNGramCounter(t *testing.T) {
+	works := []string{
+		"ἄλγε’ ἔθηκε πολλὰς δ’ ἰφθίμους",
+		"Ἄλγε’ ἔθηκε ἄλγεα ἔθηκε",
+	}
+	c := NewNGramCounter(2, 1)
+	for i, text := range works {
+		line := &Line{WorkID: string(rune('1' + i)), FormattedCitation: "1"}
+		for pos, w := range strings.Fields(text) {
+			c.Add("tlg9999", Token{Text: w, Pos: pos, Line: line})
+		}
+	}
+
+	var got []string
+	for _, g := range c.Top(1) {
+		got = append(got, strings.Join(g.Keys, " "))
+	}
+	want := []string{
+		"alge' eqhke", // accent and capital folded
+		"algea eqhke", // not alge' eqhke
+		"d' ifqimous",
+		"eqhke algea",
+		"eqhke pollas",
+		"pollas d'",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("n-grams = %q, want %q", got, want)
+	}
+	if g := c.Top(2); len(g) != 1 || g[0].Count != 2 || g[0].Examples[0].Text != "ἄλγε’ ἔθηκε" {
+		t.Errorf("Top(2) = %+v", g)
+	}
+}
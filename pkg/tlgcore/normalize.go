@@ -5,14 +5,17 @@ import (
 	"strings"
 )
 
+var (
+	latinMarks  = regexp.MustCompile(`[\^_\d\#]`)
+	strictMarks = regexp.MustCompile(`[/\(\)\\=\|\+\^_\d]`)
+)
+
 func NormalizeLatin(s string) string {
 	fields := strings.Fields(s)
 	if len(fields) == 0 {
 		return ""
 	}
-	s = fields[0]
-	re := regexp.MustCompile(`[\^_\d\#]`)
-	return strings.ToLower(re.ReplaceAllString(s, ""))
+	return strings.ToLower(latinMarks.ReplaceAllString(fields[0], ""))
 }
 
 func NormalizeStrict(s string) string {
@@ -20,9 +23,7 @@ func NormalizeStrict(s string) string {
 	if len(fields) == 0 {
 		return ""
 	}
-	s = fields[0]
-	re := regexp.MustCompile(`[/\(\)\\=\|\+\^_\d]`)
-	return strings.ToLower(re.ReplaceAllString(s, ""))
+	return strings.ToLower(strictMarks.ReplaceAllString(fields[0], ""))
 }
 
 func NormalizeFuzzy(s string) string {
@@ -3,7 +3,6 @@ package tlgcore
 import (
 	"fmt"
 	"io"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,21 +22,27 @@ type IDState struct {
 }
 
 type Parser struct {
-	File        *os.File
-	Levels      map[string]*IDState
-	Buffer      []byte
-	Pos         int
-	IsLatinFile bool
+	Src          io.ReaderAt
+	Levels       map[string]*IDState
+	Buffer       []byte
+	Pos          int
+	IsLatinFile  bool
+	IsCopticFile bool
+	// IsDocumentFile marks PHI 7 papyri and inscriptions, which are cited
+	// by document and line as they stand.
+	IsDocumentFile bool
 
 	IDTData     map[string]*WorkMetadata
 	CurrentMeta *WorkMetadata
 
 	SortedLevels []string
+
+	block int
 }
 
-func NewParser(f *os.File) *Parser {
+func NewParser(r io.ReaderAt) *Parser {
 	p := &Parser{
-		File:   f,
+		Src:    r,
 		Levels: make(map[string]*IDState),
 		Buffer: make([]byte, BlockSize),
 	}
@@ -48,35 +53,86 @@ func NewParser(f *os.File) *Parser {
 }
 
 func (p *Parser) ProcessText(s string) string {
-	if p.IsLatinFile {
-		return ToLatin(s)
+	return parseBetaCode(s, p.Script())
+}
+
+// Script is the script the text of the file starts in.
+func (p *Parser) Script() Script {
+	switch {
+	case p.IsLatinFile:
+		return ScriptLatin
+	case p.IsCopticFile:
+		return ScriptCoptic
 	}
-	return ToGreek(s)
+	return ScriptGreek
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (p *Parser) Close() error {
+	if c, ok := p.Src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 func (p *Parser) ResetInternalState() {
-	p.File.Seek(0, 0)
 	p.Pos = 0
 	for k := range levelRank {
 		p.Levels[k] = &IDState{}
 	}
 }
 
-func (p *Parser) analyzeCitationLevels() {
-	if p.CurrentMeta == nil {
-		p.SortedLevels = []string{}
-		return
+// workBlock returns the first block of a work as recorded in the IDT, or
+// 0 when it is not known.
+func (p *Parser) workBlock(workID string) int {
+	if p.IDTData == nil {
+		return 0
 	}
+	if meta := p.IDTData[workID]; meta != nil && meta.Block > 0 {
+		return meta.Block
+	}
+	return 0
+}
 
-	var levels []string
-	seen := make(map[string]bool)
+// citationBlock returns the last block known to start at or before the
+// first line matching cit, falling back to the start of the work.
+func (p *Parser) citationBlock(meta *WorkMetadata, cit []string) int {
+	block := p.workBlock(meta.ID)
+	if cit == nil {
+		return block
+	}
+
+	levels := citationLevels(meta)
+	sorted := append([]string(nil), levels...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return levelRank[sorted[i]] < levelRank[sorted[j]]
+	})
 
-	for _, cit := range p.CurrentMeta.Citations {
-		if !seen[cit.LevelChar] {
-			levels = append(levels, cit.LevelChar)
-			seen[cit.LevelChar] = true
+	for _, sec := range meta.Sections {
+		if sec.Block < block {
+			continue
+		}
+		secCit := make([]string, len(levels))
+		for i, l := range levels {
+			if st, ok := sec.Levels[l]; ok {
+				secCit[i] = levelValue(&st, l, p.sectionOrder(sorted))
+			}
+		}
+		c := compareCitation(secCit, cit)
+		if c < 0 || (c == 0 && len(cit) == len(levels)) {
+			block = sec.Block
+		} else {
+			break
 		}
 	}
+	return block
+}
+
+func (p *Parser) analyzeCitationLevels() {
+	levels := citationLevels(p.CurrentMeta)
+	if levels == nil {
+		levels = []string{}
+	}
 
 	sort.Slice(levels, func(i, j int) bool {
 		return levelRank[levels[i]] < levelRank[levels[j]]
@@ -86,68 +142,123 @@ func (p *Parser) analyzeCitationLevels() {
 }
 
 func (p *Parser) ExtractList(idtData map[string]*WorkMetadata) ([]string, error) {
-	p.ResetInternalState()
-
-	seenWorks := make(map[string]bool)
+	ids, err := p.WorkIDs(idtData)
+	if err != nil {
+		return nil, err
+	}
 	var results []string
-
-	for {
-		n, err := p.File.Read(p.Buffer)
-		if n == 0 || err == io.EOF {
-			break
+	for _, id := range ids {
+		title := "(Unknown Title)"
+		if meta, ok := idtData[id]; ok {
+			title = meta.Title
 		}
-		p.Pos = 0
-
-		for p.Pos < n {
-			b := p.Buffer[p.Pos]
-			if b&0x80 != 0 {
-				if p.parseIDByte() {
-					break
-				}
-				continue
-			}
+		results = append(results, fmt.Sprintf("ID:%-4s | %s", id, title))
+	}
+	return results, nil
+}
 
-			_ = p.readText(n)
+// WorkIDs returns the IDs of the works in the text file, in file order.
+func (p *Parser) WorkIDs(idtData map[string]*WorkMetadata) ([]string, error) {
+	seenWorks := make(map[string]bool)
+	var results []string
 
-			workState := p.Levels["b"]
-			if !workState.Active {
-				continue
+	collect := func(startBlock, maxBlocks int) error {
+		for line, err := range p.lines("", startBlock, maxBlocks) {
+			if err != nil {
+				return err
 			}
-
-			currentID := p.getCurrentWorkID()
-			if currentID == "0" {
+			if seenWorks[line.WorkID] {
 				continue
 			}
+			seenWorks[line.WorkID] = true
+			results = append(results, line.WorkID)
+		}
+		return nil
+	}
 
-			if !seenWorks[currentID] {
-				seenWorks[currentID] = true
-				title := "(Unknown Title)"
-				if meta, ok := idtData[currentID]; ok {
-					title = meta.Title
-				}
-				line := fmt.Sprintf("ID:%-4s | %s", currentID, title)
-				results = append(results, line)
-			}
+	// With block pointers from the IDT only the first block of each work
+	// needs to be read; otherwise scan the whole file.
+	blocks := workStartBlocks(idtData)
+	if blocks == nil {
+		if err := collect(0, 0); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+	for _, blk := range blocks {
+		if err := collect(blk, 1); err != nil {
+			return nil, err
 		}
 	}
 	return results, nil
 }
 
+// workStartBlocks returns the distinct starting blocks of the works in
+// idtData in file order, or nil if any work lacks one.
+func workStartBlocks(idtData map[string]*WorkMetadata) []int {
+	if len(idtData) == 0 {
+		return nil
+	}
+	seen := make(map[int]bool)
+	var blocks []int
+	for _, meta := range idtData {
+		if meta.Block < 0 {
+			return nil
+		}
+		if !seen[meta.Block] {
+			seen[meta.Block] = true
+			blocks = append(blocks, meta.Block)
+		}
+	}
+	sort.Ints(blocks)
+	return blocks
+}
+
 func (p *Parser) ExtractWork(targetWorkID string) (string, error) {
-	p.ResetInternalState()
+	var sb strings.Builder
 
-	if p.IDTData != nil {
-		p.CurrentMeta = p.IDTData[targetWorkID]
-		p.analyzeCitationLevels()
+	for line, err := range p.Lines(targetWorkID) {
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf("%-10s %s\n", line.FormattedCitation, line.Text))
+	}
+
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("work ID %s not found", targetWorkID)
+	}
+
+	return sb.String(), nil
+}
+
+// ExtractPassage formats the lines returned by PassageLines.
+func (p *Parser) ExtractPassage(targetWorkID, from, to string) (string, error) {
+	lines, err := p.PassageLines(targetWorkID, from, to)
+	if err != nil {
+		return "", err
 	}
 
 	var sb strings.Builder
-	targetInt, _ := strconv.Atoi(targetWorkID)
-	found := false
+	for _, line := range lines {
+		sb.WriteString(fmt.Sprintf("%-10s %s\n", line.FormattedCitation, line.Text))
+	}
+	return sb.String(), nil
+}
+
+// scanFrom reads up to maxBlocks blocks (0 for all) starting at startBlock
+// and calls fn for each text segment inside a work. It stops early when fn
+// returns false. Every block opens with a complete set of ID bytes, so
+// parsing can begin at any block boundary.
+func (p *Parser) scanFrom(startBlock, maxBlocks int, fn func(text string) bool) error {
+	p.ResetInternalState()
 
-	for {
-		n, err := p.File.Read(p.Buffer)
-		if n == 0 || err == io.EOF {
+	for blocks := 0; maxBlocks == 0 || blocks < maxBlocks; blocks++ {
+		p.block = startBlock + blocks
+		n, err := p.Src.ReadAt(p.Buffer, int64(p.block)*BlockSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 {
 			break
 		}
 		p.Pos = 0
@@ -171,30 +282,16 @@ func (p *Parser) ExtractWork(targetWorkID string) (string, error) {
 				continue
 			}
 
-			currentID := p.getCurrentWorkID()
-			currentInt := 0
-			if val, err := strconv.Atoi(currentID); err == nil {
-				currentInt = val
-			}
-
-			if currentID == targetWorkID || currentInt == targetInt {
-				found = true
-				output := p.ProcessText(text)
-				if strings.TrimSpace(output) != "" {
-					cit := p.formatCitation()
-					sb.WriteString(fmt.Sprintf("%-10s %s\n", cit, output))
-				}
-			} else if found {
-				return sb.String(), nil
+			if !fn(text) {
+				return nil
 			}
 		}
-	}
 
-	if sb.Len() == 0 {
-		return "", fmt.Errorf("work ID %s not found", targetWorkID)
+		if err == io.EOF {
+			break
+		}
 	}
-
-	return sb.String(), nil
+	return nil
 }
 
 func (p *Parser) getCurrentWorkID() string {
@@ -326,7 +423,7 @@ func (p *Parser) parseIDByte() bool {
 
 		isTwoRank := false
 
-		if len(p.SortedLevels) == 2 {
+		if len(p.SortedLevels) == 2 && !p.IsDocumentFile {
 			if p.SortedLevels[0] == level {
 				isTwoRank = true
 			}
@@ -420,69 +517,80 @@ func (p *Parser) readStr() string {
 
 func (p *Parser) formatCitation() string {
 	var pts []string
-	var levelsToCheck []string
 
-	if p.CurrentMeta != nil && len(p.CurrentMeta.Citations) > 0 {
-		for _, def := range p.CurrentMeta.Citations {
-			levelsToCheck = append(levelsToCheck, def.LevelChar)
+	for _, l := range p.citeLevels() {
+		if s := p.citationValue(l); s != "" {
+			pts = append(pts, s)
 		}
-	} else {
-		levelsToCheck = []string{"w", "x", "y", "z"}
 	}
 
-	isStephanus := (len(p.SortedLevels) == 3)
-	sectionLevel := ""
-	if isStephanus {
-		sectionLevel = p.SortedLevels[1]
+	if len(pts) == 0 && p.Levels["z"].Active {
+		return p.Levels["z"].ASCII
 	}
+	return strings.Join(pts, ".")
+}
 
-	seenLevel := make(map[string]bool)
-
-	for _, l := range levelsToCheck {
+func (p *Parser) citeLevels() []string {
+	if p.CurrentMeta != nil && len(p.CurrentMeta.Citations) > 0 {
+		return citationLevels(p.CurrentMeta)
+	}
+	return []string{"w", "x", "y", "z"}
+}
 
-		if seenLevel[l] {
-			continue
-		}
+// currentCitation returns the value of every level of the current work's
+// citation schema, in schema order, with "" for levels not yet set.
+func (p *Parser) currentCitation() []string {
+	levels := citationLevels(p.CurrentMeta)
+	cit := make([]string, len(levels))
+	for i, l := range levels {
+		cit[i] = p.citationValue(l)
+	}
+	return cit
+}
 
-		st := p.Levels[l]
-		if st == nil || !st.Active {
-			continue
-		}
+func (p *Parser) citationValue(l string) string {
+	st := p.Levels[l]
+	if st == nil || !st.Active {
+		return ""
+	}
+	return levelValue(st, l, p.sectionOrder(p.SortedLevels))
+}
 
-		s := st.ASCII
-		if st.Binary > 0 {
-			if isStephanus && l == sectionLevel && st.Binary >= 1 && st.Binary <= 5 {
-				if s == "" {
-					s = string('a' + byte(st.Binary-1))
-				}
-			} else {
-				s = strconv.Itoa(st.Binary) + s
-			}
-		}
+// sectionOrder is the level order levelValue reads Stephanus sections
+// from. Documents have none: a third level there is a line, not a letter.
+func (p *Parser) sectionOrder(sorted []string) []string {
+	if p.IsDocumentFile {
+		return nil
+	}
+	return sorted
+}
 
-		if s != "" {
-			pts = append(pts, s)
-			seenLevel[l] = true
-		}
+func levelValue(st *IDState, l string, sortedLevels []string) string {
+	isStephanus := (len(sortedLevels) == 3)
+	sectionLevel := ""
+	if isStephanus {
+		sectionLevel = sortedLevels[1]
 	}
 
-	if len(pts) == 0 && p.Levels["z"].Active {
-		return p.Levels["z"].ASCII
+	s := st.ASCII
+	if st.Binary > 0 {
+		if isStephanus && l == sectionLevel && st.Binary >= 1 && st.Binary <= 5 {
+			if s == "" {
+				s = string('a' + byte(st.Binary-1))
+			}
+		} else {
+			s = strconv.Itoa(st.Binary) + s
+		}
 	}
-	return strings.Join(pts, ".")
+	return s
 }
 
 func (p *Parser) ExtractAllText() (string, error) {
-	_, err := p.File.Seek(0, 0)
-	if err != nil {
-		return "", err
-	}
-
 	var sb strings.Builder
 	buf := make([]byte, BlockSize)
 
-	for {
-		n, err := p.File.Read(buf)
+	for off := int64(0); ; off += BlockSize {
+		n, err := p.Src.ReadAt(buf, off)
 		if n > 0 {
 			blockData := buf[:n]
 			for _, b := range blockData {
@@ -0,0 +1,290 @@
+package tlgcore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testID encodes an ID string as the text files write it.
+func testID(s string) []byte {
+	b := []byte(s)
+	for i := range b {
+		b[i] |= 0x80
+	}
+	return append(b, 0xff)
+}
+
+// testWork is a work of a synthetic text file: the labels of its citation
+// levels, highest first, and the citation of each of its lines.
+type testWork struct {
+	labels []string
+	lines  [][]int
+}
+
+// testCitedText builds a TLG text file for author 9999 holding the given
+// works, and its IDT. Each work starts a new block, as does every
+// perBlock'th line, and the IDT lists the block and first citation of each.
+// Citations have up to three levels, written as x, y and z.
+func testCitedText(perBlock int, works ...testWork) (text, idt []byte) {
+	cite := func(cit []int) []byte {
+		var b []byte
+		for i, v := range cit {
+			b = append(b, []byte{0xa8, 0x98, 0x88}[3-len(cit)+i], byte(v)|0x80)
+		}
+		return b
+	}
+	var block []byte
+	endBlock := func(end byte) {
+		block = append(block, end)
+		text = append(text, block...)
+		text = append(text, make([]byte, BlockSize-len(block))...)
+		block = nil
+	}
+
+	idt = append([]byte{1, 0, 0, 0, 0, 0xef, 0x80}, testID("9999")...)
+	for w, work := range works {
+		workID := strconv.Itoa(w + 1)
+		if len(block) > 0 {
+			endBlock(0xfe)
+		}
+		blk := len(text) / BlockSize
+		idt = append(idt, 2, 0, 0, byte(blk>>8), byte(blk), 0xef, 0x81)
+		idt = append(idt, testID(workID)...)
+		idt = append(idt, 16, 1, byte(len("Work "+workID)))
+		idt = append(idt, "Work "+workID...)
+		for i, label := range work.labels {
+			idt = append(idt, 17, byte(len(work.labels)-1-i), byte(len(label)))
+			idt = append(idt, label...)
+		}
+
+		for n, cit := range work.lines {
+			if n > 0 && n%perBlock == 0 {
+				endBlock(0xfe)
+			}
+			last := len(cit) - 1
+			switch {
+			case len(block) == 0:
+				blk := len(text) / BlockSize
+				idt = append(idt, 3, byte(blk>>8), byte(blk), 8)
+				idt = append(idt, cite(cit)...)
+				block = append(block, 0xef, 0x80)
+				block = append(block, testID("9999")...)
+				block = append(block, 0xef, 0x81)
+				block = append(block, testID(workID)...)
+				block = append(block, cite(cit)...)
+			case slices.Equal(work.lines[n-1][:last], cit[:last]) && work.lines[n-1][last]+1 == cit[last]:
+				block = append(block, 0x80)
+			default:
+				block = append(block, cite(cit)...)
+			}
+			block = append(block, "KAI\\ LO/GOS"...)
+		}
+	}
+	endBlock(0xf0)
+	return text, append(idt, 0)
+}
+
+// testCitedWorks are a work cited by book and line, and one cited by
+// Stephanus page, section and line, in blocks of seven lines.
+var testCitedWorks = func() []testWork {
+	books := testWork{labels: []string{"book", "line"}}
+	for b := 1; b <= 2; b++ {
+		for l := 1; l <= 20; l++ {
+			books.lines = append(books.lines, []int{b, l})
+		}
+	}
+	pages := testWork{labels: []string{"page", "section", "line"}}
+	for p := 17; p <= 19; p++ {
+		for s := 1; s <= 5; s++ {
+			for l := 1; l <= 2; l++ {
+				pages.lines = append(pages.lines, []int{p, s, l})
+			}
+		}
+	}
+	return []testWork{books, pages}
+}()
+
+// writeTestText writes the text file and IDT testCitedText builds to a
+// temporary directory and returns their paths.
+func writeTestText(t *testing.T, perBlock int, works ...testWork) (textPath, idtPath string) {
+	t.Helper()
+	text, idt := testCitedText(perBlock, works...)
+	dir := t.TempDir()
+	textPath = filepath.Join(dir, "tlg9999.txt")
+	idtPath = filepath.Join(dir, "tlg9999.idt")
+	if err := os.WriteFile(textPath, text, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(idtPath, idt, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return textPath, idtPath
+}
+
+// openTestText opens a parser on a text file with its IDT.
+func openTestText(t *testing.T, textPath, idtPath string) *Parser {
+	t.Helper()
+	f, err := os.Open(textPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	p := NewParser(f)
+	if p.IDTData, err = ReadIDT(idtPath); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// passageCitations returns the citations of the lines ExtractPassage gives.
+func passageCitations(p *Parser, workID, from, to string) ([]string, error) {
+	out, err := p.ExtractPassage(workID, from, to)
+	var cits []string
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			cits = append(cits, fields[0])
+		}
+	}
+	return cits, err
+}
+
+func TestExtractPassage(t *testing.T) {
+	textPath, idtPath := writeTestText(t, 7, testCitedWorks...)
+
+	tests := []struct {
+		work, from, to string
+		first, last    string
+		n              int
+	}{
+		{"1", "1.5", "1.9", "1.5", "1.9", 5},
+		{"1", "1.18", "2.3", "1.18", "2.3", 6},
+		{"1", "2", "", "2.1", "2.20", 20},
+		{"1", "", "1.2", "1.1", "1.2", 2},
+		{"1", "1", "1", "1.1", "1.20", 20},
+		{"1", "2.20", "2.20", "2.20", "2.20", 1},
+		// Stephanus sections run a to e within each page.
+		{"2", "17.e", "18.b", "17.e.1", "18.b.2", 6},
+		{"2", "18.c.2", "19.a.1", "18.c.2", "19.a.1", 6},
+		{"2", "19", "", "19.a.1", "19.e.2", 10},
+		{"2", "17.b.2", "17.c", "17.b.2", "17.c.2", 3},
+	}
+	for _, tt := range tests {
+		got, err := passageCitations(openTestText(t, textPath, idtPath), tt.work, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("ExtractPassage(%q, %q, %q): %v", tt.work, tt.from, tt.to, err)
+			continue
+		}
+		if len(got) != tt.n || got[0] != tt.first || got[len(got)-1] != tt.last {
+			t.Errorf("ExtractPassage(%q, %q, %q) = %q, want %d lines from %s to %s", tt.work, tt.from, tt.to, got, tt.n, tt.first, tt.last)
+		}
+	}
+}
+
+func TestExtractPassageErrors(t *testing.T) {
+	textPath, idtPath := writeTestText(t, 7, testCitedWorks...)
+
+	tests := []struct {
+		work, from, to string
+	}{
+		{"1", "2.30", "2.10"}, // reversed
+		{"1", "2", "1.5"},     // reversed
+		{"2", "18.c", "18.a"}, // reversed
+		{"2", "18", "17.e.2"}, // reversed
+		{"1", "3", ""},        // past the end
+		{"1", "1.25", "1.30"}, // between books
+		{"2", "20.a", "20.b"}, // past the end
+		{"1", "1.2.3", ""},    // too many levels
+		{"1", "1..2", ""},     // empty level
+		{"1", "1.x!", ""},     // invalid character
+		{"3", "1", "2"},       // no such work
+	}
+	for _, tt := range tests {
+		if got, err := passageCitations(openTestText(t, textPath, idtPath), tt.work, tt.from, tt.to); err == nil {
+			t.Errorf("ExtractPassage(%q, %q, %q) = %q, want an error", tt.work, tt.from, tt.to, got)
+		}
+	}
+}
+
+func TestReadIDTBlocks(t *testing.T) {
+	_, idtPath := writeTestText(t, 7, testCitedWorks...)
+	idt, err := ReadIDT(idtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		work     string
+		block    int
+		sections []int
+	}{
+		{"1", 0, []int{0, 1, 2, 3, 4, 5}},
+		{"2", 6, []int{6, 7, 8, 9, 10}},
+	}
+	for _, tt := range tests {
+		meta := idt[tt.work]
+		if meta == nil {
+			t.Errorf("work %s missing from IDT", tt.work)
+			continue
+		}
+		var sections []int
+		for _, sec := range meta.Sections {
+			sections = append(sections, sec.Block)
+		}
+		if meta.Block != tt.block || !slices.Equal(sections, tt.sections) {
+			t.Errorf("work %s: block %d, sections %v; want %d, %v", tt.work, meta.Block, sections, tt.block, tt.sections)
+		}
+	}
+}
+
+// TestExtractPassageSeek fills every block before the one the IDT leads
+// to with a copy of that block, whose lines a parser reading them would
+// give twice, and expects the passage a full scan of the intact file gives.
+func TestExtractPassageSeek(t *testing.T) {
+	textPath, idtPath := writeTestText(t, 7, testCitedWorks...)
+	text, err := os.ReadFile(textPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		work, from, to string
+		skip           int // blocks that need not be read
+	}{
+		{"1", "", "", 0},
+		{"1", "2", "2.3", 2},
+		{"1", "2.5", "2.9", 3},
+		{"2", "", "", 6},
+		{"2", "17.e", "18.b", 7},
+		{"2", "18.c.2", "19.a.1", 8},
+		{"2", "19", "", 8},
+		{"2", "19.e", "", 9},
+	}
+	for _, tt := range tests {
+		// A full scan, with the IDT's block pointers dropped.
+		p := openTestText(t, textPath, idtPath)
+		for _, meta := range p.IDTData {
+			meta.Block, meta.Sections = -1, nil
+		}
+		want, err := passageCitations(p, tt.work, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("ExtractPassage(%q, %q, %q) by full scan: %v", tt.work, tt.from, tt.to, err)
+			continue
+		}
+
+		copied := slices.Clone(text)
+		for b := 0; b < tt.skip; b++ {
+			copy(copied[b*BlockSize:], text[tt.skip*BlockSize:(tt.skip+1)*BlockSize])
+		}
+		path := filepath.Join(t.TempDir(), "tlg9999.txt")
+		if err := os.WriteFile(path, copied, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := passageCitations(openTestText(t, path, idtPath), tt.work, tt.from, tt.to)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("ExtractPassage(%q, %q, %q) from block %d = %q, %v; want %q", tt.work, tt.from, tt.to, tt.skip, got, err, want)
+		}
+	}
+}
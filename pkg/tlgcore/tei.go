@@ -0,0 +1,316 @@
+package tlgcore
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"iter"
+	"path"
+	"strings"
+)
+
+// TEIHeader holds what the teiHeader of an exported work says about it.
+type TEIHeader struct {
+	AuthorID     string // e.g. "TLG0012"
+	Author       string
+	WorkID       string
+	Title        string
+	Bibliography string // canon entry, see GetBiblioFromCanon
+}
+
+// TEIHeader collects the header of a work from the corpus' author table,
+// the author's IDT and the canon (doccan1.txt). Missing files leave the
+// corresponding fields empty.
+func (c *Corpus) TEIHeader(authorID, workID string) TEIHeader {
+	dir, base := path.Split(authorID)
+	h := TEIHeader{AuthorID: strings.ToUpper(base), WorkID: workID}
+	h.Author = c.AuthorNames(dir)[h.AuthorID]
+	if idt, err := c.IDT(authorID); err == nil && idt[workID] != nil {
+		h.Title = idt[workID].Title
+	}
+	h.Bibliography, _ = c.Biblio(authorID, workID)
+	return h
+}
+
+// teiScheme maps a citation schema onto TEI elements: every level but the
+// last becomes a nested <div type="textpart">. The last level is a verse
+// line (<l n>), a prose line (<lb n> inside one <p> per innermost div) or
+// a prose unit such as a section (<p n>).
+type teiScheme struct {
+	levels   []string
+	subtypes []string
+	verse    bool
+	lineUnit bool
+	script   Script // script the text starts in; see ParseBeta
+}
+
+func newTEIScheme(meta *WorkMetadata) teiScheme {
+	var s teiScheme
+	labels := make(map[string]string)
+	for _, def := range meta.Citations {
+		if _, ok := labels[def.LevelChar]; !ok {
+			labels[def.LevelChar] = def.Label
+		}
+	}
+	s.levels = citationLevels(meta)
+	for _, l := range s.levels {
+		s.subtypes = append(s.subtypes, teiSubtype(labels[l]))
+	}
+	if len(s.levels) == 0 {
+		return s
+	}
+
+	switch s.subtypes[len(s.subtypes)-1] {
+	case "line", "lines", "verse", "versus":
+		s.lineUnit = true
+	}
+	s.verse = s.lineUnit
+	for _, st := range s.subtypes[:len(s.subtypes)-1] {
+		for _, prose := range []string{"section", "chapter", "page", "paragraph", "column"} {
+			if strings.Contains(st, prose) {
+				s.verse = false
+			}
+		}
+	}
+	return s
+}
+
+// teiSubtype turns a citation label such as "Stephanus page" into an
+// attribute value ("stephanus-page").
+func teiSubtype(label string) string {
+	st := strings.Join(strings.Fields(strings.ToLower(label)), "-")
+	if st == "" {
+		return "part"
+	}
+	return st
+}
+
+func teiEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func teiAttr(name, val string) string {
+	if val == "" {
+		return ""
+	}
+	return fmt.Sprintf(` %s="%s"`, name, teiEscape(val))
+}
+
+// WriteTEI writes a work as a TEI P5 document, with the citation levels of
+// its IDT as nested textpart divisions and a CTS refsDecl describing them.
+func (p *Parser) WriteTEI(w io.Writer, workID string, h TEIHeader) error {
+	return p.writeTEI(w, workID, h, p.Lines(workID))
+}
+
+// WriteTEILines is WriteTEI for some lines of a work only, such as those
+// from PassageLines.
+func (p *Parser) WriteTEILines(w io.Writer, workID string, h TEIHeader, lines []Line) error {
+	return p.writeTEI(w, workID, h, lineSeq(lines))
+}
+
+func (p *Parser) writeTEI(w io.Writer, workID string, h TEIHeader, lines iter.Seq2[Line, error]) error {
+	var meta *WorkMetadata
+	if p.IDTData != nil {
+		meta = p.IDTData[workID]
+	}
+	if meta == nil {
+		return fmt.Errorf("work ID %s not found in IDT", workID)
+	}
+	s := newTEIScheme(meta)
+	s.script = p.Script()
+
+	bw := bufio.NewWriter(w)
+	writeTEIHeader(bw, h, s)
+	fmt.Fprintf(bw, "  <text xml:lang=\"%s\">\n    <body>\n      <div type=\"edition\">\n", p.teiLang())
+	found, err := writeTEIBody(bw, s, lines, 4)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("work ID %s not found", workID)
+	}
+	fmt.Fprint(bw, "      </div>\n    </body>\n  </text>\n</TEI>\n")
+	return bw.Flush()
+}
+
+// WriteTEIPassage writes lines of a work, such as those from PassageLines,
+// as a bare <TEI> element holding only the text. This is the passage
+// returned by the CTS GetPassage request.
+func (p *Parser) WriteTEIPassage(w io.Writer, workID string, lines []Line) error {
+	var meta *WorkMetadata
+	if p.IDTData != nil {
+		meta = p.IDTData[workID]
+	}
+	if meta == nil {
+		return fmt.Errorf("work ID %s not found in IDT", workID)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<TEI xmlns=\"http://www.tei-c.org/ns/1.0\">\n  <text xml:lang=\"%s\">\n    <body>\n      <div type=\"edition\">\n", p.teiLang())
+	s := newTEIScheme(meta)
+	s.script = p.Script()
+	_, err := writeTEIBody(bw, s, lineSeq(lines), 4)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(bw, "      </div>\n    </body>\n  </text>\n</TEI>\n")
+	return bw.Flush()
+}
+
+// lineSeq yields lines as Parser.Lines does.
+func lineSeq(lines []Line) iter.Seq2[Line, error] {
+	return func(yield func(Line, error) bool) {
+		for _, l := range lines {
+			if !yield(l, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (p *Parser) teiLang() string {
+	return p.Script().Lang()
+}
+
+// writeTEIBody writes the divisions and lines of a work at the given
+// indent depth and reports whether there were any lines. Line text keeps
+// its editorial markup, see BetaTree.TEI.
+func writeTEIBody(bw *bufio.Writer, s teiScheme, lines iter.Seq2[Line, error], depth int) (bool, error) {
+	divs := max(len(s.levels)-1, 0)
+	var open []string
+	pOpen := false
+	unit := ""
+	indent := func() string { return strings.Repeat("  ", depth+len(open)) }
+	closeP := func() {
+		if !pOpen {
+			return
+		}
+		if s.lineUnit || len(s.levels) == 0 {
+			fmt.Fprintf(bw, "\n%s</p>\n", indent())
+		} else {
+			fmt.Fprint(bw, "</p>\n")
+		}
+		pOpen = false
+	}
+
+	found := false
+	for line, err := range lines {
+		if err != nil {
+			return found, err
+		}
+		found = true
+
+		vals := make([]string, divs)
+		for i := range vals {
+			vals[i] = line.Citation[s.levels[i]]
+		}
+		k := 0
+		for k < len(open) && open[k] == vals[k] {
+			k++
+		}
+		if k < len(open) {
+			closeP()
+			for len(open) > k {
+				open = open[:len(open)-1]
+				fmt.Fprintf(bw, "%s</div>\n", indent())
+			}
+		}
+		for len(open) < divs {
+			i := len(open)
+			fmt.Fprintf(bw, "%s<div type=\"textpart\"%s%s>\n", indent(), teiAttr("subtype", s.subtypes[i]), teiAttr("n", vals[i]))
+			open = append(open, vals[i])
+		}
+
+		n := line.FormattedCitation
+		if len(s.levels) > 0 {
+			n = line.Citation[s.levels[len(s.levels)-1]]
+		}
+		text := strings.TrimSpace(ParseBeta(line.RawBetaCode, s.script).TEI())
+		switch {
+		case s.verse:
+			fmt.Fprintf(bw, "%s<l%s>%s</l>\n", indent(), teiAttr("n", n), text)
+		case s.lineUnit || len(s.levels) == 0:
+			if !pOpen {
+				fmt.Fprintf(bw, "%s<p>", indent())
+				pOpen = true
+			}
+			fmt.Fprintf(bw, "\n%s  <lb%s/>%s", indent(), teiAttr("n", n), text)
+		default:
+			if pOpen && n != unit {
+				closeP()
+			}
+			if !pOpen {
+				fmt.Fprintf(bw, "%s<p%s>%s", indent(), teiAttr("n", n), text)
+				pOpen, unit = true, n
+			} else {
+				fmt.Fprintf(bw, "\n%s  <lb/>%s", indent(), text)
+			}
+		}
+	}
+	closeP()
+	for len(open) > 0 {
+		open = open[:len(open)-1]
+		fmt.Fprintf(bw, "%s</div>\n", indent())
+	}
+	return found, nil
+}
+
+func writeTEIHeader(w *bufio.Writer, h TEIHeader, s teiScheme) {
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<TEI xmlns="http://www.tei-c.org/ns/1.0">
+  <teiHeader>
+    <fileDesc>
+      <titleStmt>
+`)
+	fmt.Fprintf(w, "        <title>%s</title>\n", teiEscape(h.Title))
+	fmt.Fprintf(w, "        <author>%s</author>\n", teiEscape(h.Author))
+	fmt.Fprint(w, "      </titleStmt>\n      <publicationStmt>\n")
+	fmt.Fprintf(w, "        <p>Converted from %s by lyceum.</p>\n", teiEscape(h.AuthorID))
+	fmt.Fprint(w, "      </publicationStmt>\n      <sourceDesc>\n        <bibl>\n")
+	fmt.Fprintf(w, "          <author>%s</author>\n", teiEscape(h.Author))
+	fmt.Fprintf(w, "          <title>%s</title>\n", teiEscape(h.Title))
+	fmt.Fprintf(w, "          <idno type=\"author\">%s</idno>\n", teiEscape(h.AuthorID))
+	fmt.Fprintf(w, "          <idno type=\"work\">%s</idno>\n", teiEscape(h.WorkID))
+	fmt.Fprint(w, "        </bibl>\n")
+	if b := strings.TrimSpace(h.Bibliography); b != "" {
+		var lines []string
+		for _, l := range strings.Split(b, "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, teiEscape(l))
+			}
+		}
+		fmt.Fprintf(w, "        <bibl type=\"canon\">%s</bibl>\n", strings.Join(lines, "<lb/>"))
+	}
+	fmt.Fprint(w, "      </sourceDesc>\n    </fileDesc>\n    <encodingDesc>\n      <refsDecl n=\"CTS\">\n")
+
+	divs := max(len(s.levels)-1, 0)
+	for d := len(s.levels); d >= 1; d-- {
+		var match []string
+		xpath := "/tei:TEI/tei:text/tei:body/tei:div"
+		for i := 1; i <= d; i++ {
+			match = append(match, `(\w+)`)
+			switch {
+			case i <= divs:
+				xpath += fmt.Sprintf("/tei:div[@n='$%d']", i)
+			case s.verse:
+				xpath += fmt.Sprintf("/tei:l[@n='$%d']", i)
+			case s.lineUnit:
+				xpath += fmt.Sprintf("/tei:p/tei:lb[@n='$%d']", i)
+			default:
+				xpath += fmt.Sprintf("/tei:p[@n='$%d']", i)
+			}
+		}
+		fmt.Fprintf(w, "        <cRefPattern n=\"%s\" matchPattern=\"%s\" replacementPattern=\"#xpath(%s)\">\n",
+			teiEscape(s.subtypes[d-1]), strings.Join(match, `\.`), xpath)
+		names := strings.Join(s.subtypes[:d], ", ")
+		if d > 1 {
+			names = strings.Join(s.subtypes[:d-1], ", ") + " and " + s.subtypes[d-1]
+		}
+		fmt.Fprintf(w, "          <p>This pointer pattern extracts %s.</p>\n", teiEscape(names))
+		fmt.Fprint(w, "        </cRefPattern>\n")
+	}
+	fmt.Fprint(w, "      </refsDecl>\n    </encodingDesc>\n  </teiHeader>\n")
+}
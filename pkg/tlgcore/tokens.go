@@ -0,0 +1,253 @@
+package tlgcore
+
+import (
+	"iter"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a word of running text together with the line it occurs on.
+// A word hyphenated at the end of a line is one token: Line, Start and End
+// locate its first part (without the hyphen), Next, NextStart and NextEnd
+// the rest on the following line.
+type Token struct {
+	Text  string // the word as decoded, without editorial brackets
+	Key   string // normalized form used for matching, see WordKey
+	Pos   int    // ordinal of the token within its work
+	Line  *Line
+	Start int // byte offsets of the word in Line.Text
+	End   int
+
+	Next      *Line // line the word continues on, nil if it does not span lines
+	NextStart int   // byte offsets of the continuation in Next.Text
+	NextEnd   int
+}
+
+// Citation returns the citation of the token's line, or the range of
+// both lines ("1.4-1.5") for a word that spans two.
+func (t Token) Citation() string {
+	if t.Next != nil && t.Next.FormattedCitation != t.Line.FormattedCitation {
+		return t.Line.FormattedCitation + "-" + t.Next.FormattedCitation
+	}
+	return t.Line.FormattedCitation
+}
+
+// Tokens iterates over the words of a work, or of every work in the file
+// when workID is empty. Pos restarts at 0 with each work.
+func (p *Parser) Tokens(workID string) iter.Seq2[Token, error] {
+	return tokensOf(p.Lines(workID))
+}
+
+// TokensFrom iterates over the words of a work found in up to maxBlocks
+// blocks starting at block. Pos counts from the first word read.
+func (p *Parser) TokensFrom(workID string, block, maxBlocks int) iter.Seq2[Token, error] {
+	return tokensOf(p.lines(workID, block, maxBlocks))
+}
+
+// tokensOf splits lines into tokens, rejoining words hyphenated across
+// the end of a line within the same work.
+func tokensOf(lines iter.Seq2[Line, error]) iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		pos := 0
+		currentWork := ""
+		var held *Token // first part of a word hyphenated at the end of the last line
+
+		emit := func(tok Token) bool {
+			tok.Pos = pos
+			pos++
+			return yield(tok, nil)
+		}
+
+		for line, err := range lines {
+			if err != nil {
+				yield(Token{}, err)
+				return
+			}
+			ln := line
+			spans := wordSpans(line.Text)
+
+			if held != nil {
+				tok := *held
+				held = nil
+				if line.WorkID == tok.Line.WorkID && len(spans) > 0 {
+					w := spans[0]
+					spans = spans[1:]
+					tok.Text += w.text
+					tok.Next, tok.NextStart, tok.NextEnd = &ln, w.start, w.end
+				}
+				if key := WordKey(tok.Text); key != "" {
+					tok.Key = key
+					if !emit(tok) {
+						return
+					}
+				}
+			}
+			if line.WorkID != currentWork {
+				currentWork = line.WorkID
+				pos = 0
+			}
+
+			for i, w := range spans {
+				key := WordKey(w.text)
+				if key == "" {
+					continue
+				}
+				tok := Token{Text: w.text, Key: key, Line: &ln, Start: w.start, End: w.end}
+				if i == len(spans)-1 && endsHyphenated(line.Text[w.end:]) {
+					held = &tok
+					break
+				}
+				if !emit(tok) {
+					return
+				}
+			}
+		}
+		if held != nil {
+			emit(*held)
+		}
+	}
+}
+
+// endsHyphenated reports whether the text after the last word of a line is
+// a hyphen, possibly with editorial brackets and spaces.
+func endsHyphenated(rest string) bool {
+	rest = strings.TrimFunc(rest, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("()[]{}⟦⟧⌊⌋⌈⌉⟨⟩", r)
+	})
+	return rest == "-" || rest == "‐"
+}
+
+// Words splits decoded Greek or Latin text into words. Editorial brackets
+// inside a word (ἀχιλ(ῆος)) are dropped rather than treated as breaks, and
+// elision marks stay attached to the word they end.
+func Words(s string) []string {
+	var words []string
+	for _, w := range wordSpans(s) {
+		words = append(words, w.text)
+	}
+	return words
+}
+
+type wordSpan struct {
+	text       string
+	start, end int
+}
+
+func wordSpans(s string) []wordSpan {
+	var words []wordSpan
+	var cur strings.Builder
+	start, end := 0, 0
+
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, wordSpan{cur.String(), start, end})
+			cur.Reset()
+		}
+	}
+
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.Is(unicode.Mn, r):
+			if cur.Len() == 0 {
+				start = i
+			}
+			cur.WriteRune(r)
+			end = i + utf8.RuneLen(r)
+		case isElision(r):
+			if cur.Len() > 0 {
+				cur.WriteRune(r)
+				end = i + utf8.RuneLen(r)
+			}
+		case strings.ContainsRune("()[]{}⟦⟧⌊⌋⌈⌉⟨⟩", r):
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+func isElision(r rune) bool {
+	return r == '’' || r == '\'' || r == 'ʼ'
+}
+
+// WordKey reduces a word to the diacritic-free, lower-case Beta Code form
+// ("Ἀχαιοῖς" -> "axaiois"): each letter is decomposed, its marks dropped
+// and its base mapped to a-z. Queries typed in Beta Code go through the
+// same reduction, as their diacritics, digits and * are not letters.
+func WordKey(w string) string {
+	var out strings.Builder
+	for _, r := range w {
+		if unicode.IsSpace(r) {
+			if out.Len() > 0 {
+				break
+			}
+			continue
+		}
+		if b := keyLetter(r); b != 0 {
+			out.WriteByte(b)
+		}
+	}
+	return out.String()
+}
+
+// keyLetters maps each Greek and Coptic letter with a Beta Code to its
+// letter a-z, final and lunate sigma included.
+var keyLetters = func() map[rune]byte {
+	m := make(map[rune]byte)
+	for r, beta := range AlphaBase {
+		var b byte
+		for i := 0; i < len(beta); i++ {
+			if c := beta[i] | 0x20; c >= 'a' && c <= 'z' {
+				b = c
+				break
+			}
+		}
+		if b != 0 {
+			m[r] = b
+		}
+	}
+	m['ς'], m['ϲ'], m['Ϲ'] = 's', 's', 's'
+	for b, c := range copticBase {
+		if b >= 'a' && b <= 'z' {
+			m[c], m[unicode.ToUpper(c)] = byte(b), byte(b)
+		}
+	}
+	for code, c := range copticExtra {
+		m[c], m[unicode.ToUpper(c)] = code[0], code[0]
+	}
+	return m
+}()
+
+// keyLetter gives the letter a-z of r in a WordKey, or 0.
+func keyLetter(r rune) byte {
+	for {
+		switch {
+		case r < utf8.RuneSelf:
+			if c := byte(r) | 0x20; c >= 'a' && c <= 'z' {
+				return c
+			}
+			return 0
+		case keyLetters[r] != 0:
+			return keyLetters[r]
+		}
+		d, ok := CanonicalDecomposition[r]
+		if !ok {
+			return 0
+		}
+		r, _ = utf8.DecodeRuneInString(d)
+	}
+}
+
+// QueryKeys turns a word or phrase typed in Greek or Beta Code into the
+// keys of its words.
+func QueryKeys(q string) []string {
+	var keys []string
+	for _, f := range strings.Fields(q) {
+		if k := WordKey(f); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
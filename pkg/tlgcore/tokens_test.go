@@ -0,0 +1,64 @@
+package tlgcore
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWordKey(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		// Greek
+		{"Ἀχαιοῖς", "axaiois"},
+		{"ᾠδῇ", "wdh"},
+		{"Πηληϊάδεω", "phlhiadew"},
+		{"ΟΔΥΣΣΕΥΣ", "odusseus"},
+		{"ϲοφία", "sofia"},
+		{"λόγος,", "logos"},
+		{"μυρί’", "muri"},
+		{"  μῆνιν ἄειδε", "mhnin"},
+		// Beta Code
+		{"*)AXAIOI=S", "axaiois"},
+		{"*PHLHI+A/DEW", "phlhiadew"},
+		{"W)|DH=|", "wdh"},
+		{"a)/lge'", "alge"},
+		{"LO/GOS", "logos"},
+		{"S3OFI/A", "sofia"},
+		// Latin
+		{"Caesar,", "caesar"},
+		{"QVE", "qve"},
+		{"cāna", "cana"},
+		{"Vergilĭus", "vergilius"},
+		{"Arma virumque", "arma"},
+		// Coptic
+		{"ⲁⲛⲟⲕ", "anok"},
+		// No letters
+		{"", ""},
+		{"123", ""},
+		{"· —", ""},
+	}
+	for _, tt := range tests {
+		if got := WordKey(tt.word); got != tt.want {
+			t.Errorf("WordKey(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestQueryKeys(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ὁ λόγος", []string{"o", "logos"}},
+		{"O( LO/GOS", []string{"o", "logos"}},
+		{"  μῆνιν   ἄειδε ", []string{"mhnin", "aeide"}},
+		{"καὶ · θεός", []string{"kai", "qeos"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := QueryKeys(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("QueryKeys(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
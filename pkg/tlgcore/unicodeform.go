@@ -0,0 +1,212 @@
+package tlgcore
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputForm is the Unicode normalization form text is written in. The
+// package decodes to NFC; commands put their output in another form with
+// a FormWriter.
+type OutputForm int
+
+const (
+	NFC     OutputForm = iota // composed; acute vowels with tonos (ά U+03AC)
+	NFCOxia                   // composed; acute vowels with oxia (ά U+1F71)
+	NFD                       // base letters followed by combining marks
+)
+
+var outputFormNames = []string{"nfc", "nfc-oxia", "nfd"}
+
+// OutputFormUsage is the usage of the -norm flag.
+const OutputFormUsage = "Unicode form of the output: nfc (acute as tonos), nfc-oxia (acute as oxia) or nfd"
+
+func (f OutputForm) String() string {
+	return outputFormNames[f]
+}
+
+// Set sets the form from its name, so that a form can be a flag.Value.
+func (f *OutputForm) Set(s string) error {
+	for i, name := range outputFormNames {
+		if s == name {
+			*f = OutputForm(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown form %q (want %s)", s, strings.Join(outputFormNames, ", "))
+}
+
+// tonosToOxia maps the vowels with tonos, which NFC prefers, to the
+// Greek Extended vowels with oxia.
+var tonosToOxia = func() map[rune]rune {
+	m := make(map[rune]rune, len(oxiaToTonos))
+	for oxia, tonos := range oxiaToTonos {
+		m[tonos] = oxia
+	}
+	return m
+}()
+
+// compositions maps a starter and the mark that follows it to the
+// precomposed character, as NFC combines them.
+var compositions = func() map[[2]rune]rune {
+	m := make(map[[2]rune]rune)
+	for r, d := range CanonicalDecomposition {
+		rs := []rune(d)
+		if len(rs) == 2 && CombiningClass[rs[0]] == 0 {
+			m[[2]rune{rs[0], rs[1]}] = r
+		}
+	}
+	return m
+}()
+
+// Apply writes s in the form f. Only Latin and Greek letters are composed
+// and decomposed; combining marks of any script are put in canonical order.
+func (f OutputForm) Apply(s string) string {
+	if f.holds(s) {
+		return s
+	}
+	runes := decomposeRunes(s)
+	if f == NFD {
+		return string(runes)
+	}
+	runes = composeRunes(runes)
+	if f == NFCOxia {
+		for i, r := range runes {
+			if o, ok := tonosToOxia[r]; ok {
+				runes[i] = o
+			}
+		}
+	}
+	return string(runes)
+}
+
+// holds reports whether s is already in the form, which most decoded
+// text is.
+func (f OutputForm) holds(s string) bool {
+	for _, r := range s {
+		if r < 0xC0 {
+			continue
+		}
+		if CombiningClass[r] != 0 {
+			return false
+		}
+		switch d, ok := CanonicalDecomposition[r]; {
+		case !ok:
+		case f == NFD:
+			return false
+		case len([]rune(d)) == 1:
+			return false // singletons such as ά U+1F71 and ; U+037E
+		case f == NFCOxia && tonosToOxia[r] != 0:
+			return false
+		}
+	}
+	return true
+}
+
+func decomposeRunes(s string) []rune {
+	var out []rune
+	var add func(r rune)
+	add = func(r rune) {
+		if d, ok := CanonicalDecomposition[r]; ok {
+			for _, c := range d {
+				add(c)
+			}
+			return
+		}
+		out = append(out, r)
+	}
+	for _, r := range s {
+		add(r)
+	}
+	for i := 1; i < len(out); i++ {
+		c := CombiningClass[out[i]]
+		for j := i; j > 0 && c != 0 && CombiningClass[out[j-1]] > c; j-- {
+			out[j-1], out[j] = out[j], out[j-1]
+		}
+	}
+	return out
+}
+
+// composeRunes is the canonical composition of decomposed text.
+func composeRunes(rs []rune) []rune {
+	if len(rs) == 0 {
+		return rs
+	}
+	out := rs[:1]
+	starter := 0
+	last := 256 // a leading mark composes with nothing
+	if CombiningClass[rs[0]] == 0 {
+		last = 0
+	}
+	for _, r := range rs[1:] {
+		c := int(CombiningClass[r])
+		if comp, ok := compositions[[2]rune{out[starter], r}]; ok && (last < c || last == 0) {
+			out[starter] = comp
+			continue
+		}
+		if c == 0 {
+			starter = len(out)
+		}
+		last = c
+		out = append(out, r)
+	}
+	return out
+}
+
+// FormWriter puts the NFC text written to it in Form before passing it on.
+// A letter that its marks may still follow is held back until the next
+// write or Flush, so writes may split text anywhere.
+type FormWriter struct {
+	Form OutputForm
+
+	w    io.Writer
+	held []byte
+}
+
+func NewFormWriter(w io.Writer, f OutputForm) *FormWriter {
+	return &FormWriter{Form: f, w: w}
+}
+
+func (fw *FormWriter) Write(p []byte) (int, error) {
+	if fw.Form == NFC && len(fw.held) == 0 {
+		return fw.w.Write(p)
+	}
+	fw.held = append(fw.held, p...)
+	if n := settled(fw.held); n > 0 {
+		if _, err := io.WriteString(fw.w, fw.Form.Apply(string(fw.held[:n]))); err != nil {
+			return 0, err
+		}
+		fw.held = fw.held[:copy(fw.held, fw.held[n:])]
+	}
+	return len(p), nil
+}
+
+// Flush writes out what is held back.
+func (fw *FormWriter) Flush() error {
+	if len(fw.held) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(fw.w, fw.Form.Apply(string(fw.held)))
+	fw.held = fw.held[:0]
+	return err
+}
+
+// settled returns the length of the start of b that text written after it
+// cannot change: all of b if it ends in a starter with no decomposition,
+// else up to its last starter.
+func settled(b []byte) int {
+	for i := len(b); i > 0; {
+		r, size := utf8.DecodeLastRune(b[:i])
+		i -= size
+		if r == utf8.RuneError && size == 1 || CombiningClass[r] != 0 {
+			continue // part of a split rune, or a mark
+		}
+		if _, ok := CanonicalDecomposition[r]; !ok && i+size == len(b) {
+			return len(b)
+		}
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package tlgcore
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutputFormApply(t *testing.T) {
+	tests := []struct {
+		in             string
+		nfc, oxia, nfd string
+	}{
+		{"\u03AC", "\u03AC", "\u1F71", "\u03B1\u0301"},                               // ά with tonos
+		{"\u1F71", "\u03AC", "\u1F71", "\u03B1\u0301"},                               // ά with oxia
+		{"\u03B1\u0301", "\u03AC", "\u1F71", "\u03B1\u0301"},                         // ά decomposed
+		{"\u1F04", "\u1F04", "\u1F04", "\u03B1\u0313\u0301"},                         // ἄ
+		{"\u1F84", "\u1F84", "\u1F84", "\u03B1\u0313\u0301\u0345"},                   // ᾄ
+		{"\u03B1\u0345\u0313\u0301", "\u1F84", "\u1F84", "\u03B1\u0313\u0301\u0345"}, // ᾄ, marks out of order
+		{"\u038C", "\u038C", "\u1FF9", "\u039F\u0301"},                               // Ό
+		{"\u0390", "\u0390", "\u1FD3", "\u03B9\u0308\u0301"},                         // ΐ
+		{"\u037E", ";", ";", ";"},                                                    // Greek question mark
+		{"\u00E9", "\u00E9", "\u00E9", "e\u0301"},                                    // Latin é
+		{"\u1FC6\u03BD", "\u1FC6\u03BD", "\u1FC6\u03BD", "\u03B7\u0342\u03BD"},       // ῆν
+		{"", "", "", ""},
+	}
+	for _, tt := range tests {
+		for _, c := range []struct {
+			form OutputForm
+			want string
+		}{{NFC, tt.nfc}, {NFCOxia, tt.oxia}, {NFD, tt.nfd}} {
+			if got := c.form.Apply(tt.in); got != c.want {
+				t.Errorf("%v.Apply(%+q) = %+q, want %+q", c.form, tt.in, got, c.want)
+			}
+		}
+	}
+}
+
+func TestOutputFormSet(t *testing.T) {
+	for _, f := range []OutputForm{NFC, NFCOxia, NFD} {
+		var got OutputForm
+		if err := got.Set(f.String()); err != nil || got != f {
+			t.Errorf("Set(%q) = %v, %v", f.String(), got, err)
+		}
+	}
+	var f OutputForm
+	if err := f.Set("nfkc"); err == nil {
+		t.Error("Set accepted nfkc")
+	}
+}
+
+// TestFormWriter writes NFC text one byte at a time, splitting runes and
+// letters from their marks, and expects what Apply gives for the whole.
+func TestFormWriter(t *testing.T) {
+	texts := []string{
+		"\u03BC\u1FC6\u03BD\u03B9\u03BD \u1F04\u03B5\u03B9\u03B4\u03B5 \u03B8\u03B5\u1F70\n",
+		"\u1F84 \u03AC\n\u03B1",
+		"\u1FA0\u03B4\u1FC7 ; \u00E9",
+		"\u0301 leading mark",
+	}
+	for _, form := range []OutputForm{NFC, NFCOxia, NFD} {
+		for _, s := range texts {
+			var buf bytes.Buffer
+			fw := NewFormWriter(&buf, form)
+			for i := 0; i < len(s); i++ {
+				if _, err := fw.Write([]byte{s[i]}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := fw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if want := form.Apply(s); buf.String() != want {
+				t.Errorf("%v: FormWriter wrote %+q, want %+q", form, buf.String(), want)
+			}
+		}
+	}
+}